@@ -10,33 +10,87 @@
 //
 // Available Commands:
 //
+//	cd          Change to a worktree of the project.
 //	cl          Clone a repo for a git-wt workflow.
 //	completion  Generate the autocompletion script for the specified shell
+//	default     Switch the project to the remote's default branch.
+//	doctor      Check the project for problems.
+//	exec        Run a command in every worktree of the project.
 //	help        Help about any command
+//	lock        Lock a worktree of the project.
 //	ls          List worktrees for the project.
 //	mk          Add a worktree to the project.
 //	mv          Move a worktree within the project.
+//	pick        Pick a worktree of the project interactively.
+//	relocate    Move the project folder.
+//	repair      Repair the worktree links of the project.
 //	rm          Remove a worktree from the project.
+//	shell-init  Print shell integration for changing to worktrees.
+//	sync        Update the worktrees of the project from the remote.
+//	ui          Show a terminal dashboard for the project.
+//	undo        Undo the last change to the project.
+//	unlock      Unlock a worktree of the project.
 //	xx          Reset project.//
 //
 // Flags:
 //
-//	-d, --debug     enable debug mode
-//	-h, --help      help for git-wt
-//	-v, --version   version for git-wt
+//	-d, --debug                log at the debug level; same as --log-level debug
+//	-h, --help                 help for git-wt
+//	    --lock-wait duration   how long to wait for another git-wt changing the project; fail at once if 0
+//	    --log-format string    log format: text or json (default "text")
+//	    --log-level string     log level: trace, debug, info, warn or error (default "warn")
+//	    --timeout duration     time limit of the command, e.g. 30s or 5m; none if 0
+//	-v, --version              version for git-wt
+//
+// Set GIT_WT_LOG_FILE to append the log to a file instead of stderr.
 //
 // Use "git-wt [command] --help" for more information about a command.
+//
+// Interrupting a command with Ctrl-C, or running past its --timeout, stops it
+// at the next safe point, reporting what it had done. A second Ctrl-C kills it
+// at once.
+//
+// Exit Codes:
+//
+//	0    success
+//	1    any other failure
+//	2    invalid flags, arguments or worktree name
+//	3    not in a git-wt project
+//	4    no such worktree
+//	5    worktree has uncommitted changes
+//	6    worktree is locked
+//	7    current working directory within the worktree
+//	8    project, worktree, branch or path already exists
+//	9    remote could not be reached or read
+//	10   another git-wt holds the project's lock
+//	124  command ran past its --timeout
+//	130  command was interrupted
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 
+	"github.com/jason-dour/git-wt/internal/cmn"
 	"github.com/jason-dour/git-wt/internal/cobra/root"
 )
 
 func main() {
-	err := root.Cmd.Execute()
+	// Cancel the commands on the first interrupt, letting them stop at a safe
+	// point; the next one kills the program.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	go func() {
+		<-signals
+		signal.Stop(signals)
+		cancel(cmn.ErrInterrupted)
+	}()
+
+	err := root.New().ExecuteContext(ctx)
 	if err != nil {
-		os.Exit(1)
+		os.Exit(cmn.ExitCode(err))
 	}
 }
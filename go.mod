@@ -5,10 +5,12 @@ go 1.23.2
 require (
 	github.com/gogs/git-module v1.8.3
 	github.com/spf13/cobra v1.8.1
+	golang.org/x/term v0.27.0
 )
 
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/mcuadros/go-version v0.0.0-20190308113854-92cdf37c5b75 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sys v0.28.0 // indirect
 )
@@ -4,39 +4,89 @@ package cmn
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 type (
-	Cfg struct {
-		DebugFlag     bool   // Whether debug output is enabled.
-		DefaultBranch string // Default branch/worktree of the project.
-		InitialDir    string // Initial working directory of the program.
-		ProjectDir    string // Path of the project directory.
-	} // Configuration for the program.
+	Project struct {
+		Copy          []string            // Glob patterns of local files carried into new worktrees.
+		CopyMode      string              // How local files are carried: copy, hardlink, reflink or symlink.
+		DefaultBranch string              // Default branch/worktree of the project.
+		Fetch         bool                // Whether 'mk' fetches the remote before resolving commit-ish.
+		HookFailure   string              // Policy for failing hooks: abort or warn.
+		Hooks         map[string][]string // Hook commands by hook name, in configured order.
+		InitialDir    string              // Directory the project was opened from.
+		MvBranch      bool                // Whether 'mv' renames the checked-out branch with the worktree.
+		Picker        string              // Interactive picker: builtin or fzf.
+		ProjectDir    string              // Path of the project directory.
+		Remote        string              // Name of the remote used by the project.
+		Runner        Runner              // Runs git for the project; the git binary if nil.
+		Slashes       string              // Policy for slashes in worktree names: nest, flatten or reject.
+	} // Configuration of a project, as opened from a directory within it.
+
+	Runner interface {
+		Run(ctx context.Context, dir string, args ...string) ([]byte, error) // Runs git with args in dir, returning its standard output.
+	} // Runs git commands; implemented by git.Module and, in tests, gittest.Fake.
+
+	CfgExec struct {
+		Filters  []string // Filters selecting worktrees: name=, branch= or status=.
+		Parallel int      // Number of worktrees to run in at once.
+	} // Configuration for 'exec' command.
 
 	CfgMk struct {
 		Branch      string
 		BranchReset string
 		CheckoutNo  bool
+		CopyNo      bool
+		Cd          bool
+		Fetch       bool
 		Force       bool
+		FromDefault bool
+		Lock        bool
+		LockReason  string
 		Track       bool
 		Quiet       bool
 		RefId       string
 	} // Configuration for 'mk' command.
 
 	CfgMv struct {
-		Force bool
+		Branch   bool // Whether to rename the checked-out branch to the new worktree name.
+		Force    bool // Whether to move onto the path of a missing worktree.
+		Upstream bool // Whether to point the renamed branch at its namesake on the remote.
 	} // Configuration for 'mv' command.
 
+	CfgLock struct {
+		Reason string // Reason for locking the worktree.
+	} // Configuration for 'lock' command.
+
+	CfgRepair struct {
+		Prune bool // Whether to prune worktrees whose directories are missing.
+	} // Configuration for 'repair' command.
+
 	CfgRm struct {
-		Force bool
+		Force  int  // Number of times removal is forced; twice removes locked worktrees.
+		Merged bool // Whether to remove worktrees whose branch is merged.
 	} // Configuration for 'rm' command.
 
+	CfgSync struct {
+		FfOnly bool // Whether to fast-forward worktrees onto the default branch.
+		Merge  bool // Whether to merge the default branch into worktrees.
+		Rebase bool // Whether to rebase worktrees onto the default branch.
+	} // Configuration for 'sync' command.
+
+	CfgUndo struct {
+		Force  int  // Number of times undoing is forced; twice undoes locked worktrees.
+		DryRun bool // Whether to only show what would be undone.
+	} // Configuration for 'undo' command.
+
 	CfgXx struct {
+		Force     int  // Number of times deletion is forced; twice deletes locked worktrees.
 		Branches  bool // Whether to reset branches.
 		Worktrees bool // Whether to reset worktrees.
 		Most      bool // Whether to reset both branches and worktrees.
@@ -45,130 +95,429 @@ type (
 )
 
 var (
-	Basename string          // Base name of the program; injected during compile.
-	Version  string          // Version of the program; injected during compile.
-	Config   *Cfg   = &Cfg{} // Global configuration for the program.
+	Basename string = "git-wt" // Base name of the program; injected during compile.
+	Version  string            // Version of the program; injected during compile.
 )
 
-// Debug writes debug output to Stderr if DebugFlag is true.
-func Debug(format string, args ...interface{}) {
-	if Config.DebugFlag {
-		fmt.Fprintln(os.Stderr, fmt.Sprintf("debug: "+format, args...))
+const (
+	DefaultRemote = "origin"          // Remote used when the config file does not name one.
+	CdFileEnv     = "GIT_WT_CD_FILE"  // Environment variable naming the shell integration's cd file.
+	LogFileEnv    = "GIT_WT_LOG_FILE" // Environment variable naming a file to log to instead of stderr.
+	LockWaitFlag  = "lock-wait"       // Flag of the root command for how long to wait for the project's lock.
+
+	SlashesNest    = "nest"    // Slashes in worktree names create nested directories.
+	SlashesFlatten = "flatten" // Slashes in worktree names are replaced with '-'.
+	SlashesReject  = "reject"  // Slashes in worktree names are an error.
+
+	HookFailureAbort = "abort" // A failing hook aborts the command.
+	HookFailureWarn  = "warn"  // A failing hook prints a warning.
+
+	CopyModeCopy     = "copy"     // Local files are copied.
+	CopyModeHardlink = "hardlink" // Local files are hard-linked.
+	CopyModeReflink  = "reflink"  // Local files are reflinked, falling back to copy.
+	CopyModeSymlink  = "symlink"  // Local files are symlinked.
+
+	PickerBuiltin = "builtin" // Worktrees are picked with the built-in picker.
+	PickerFzf     = "fzf"     // Worktrees are picked with fzf, if found in PATH.
+)
+
+var (
+	HookNames = []string{"post-mk", "pre-rm", "post-rm", "post-mv", "post-clone"} // Names of supported hooks.
+)
+
+// StateDir returns the path of the project's state directory.
+func (p *Project) StateDir() string {
+	return filepath.Join(p.ProjectDir, "."+Basename+".d")
+}
+
+// ReadState reads a named value from the project's state directory.
+func (p *Project) ReadState(name string) (string, error) {
+	funcName := "cmn.ReadState"
+	Trace(funcName, "begin")
+
+	value, err := os.ReadFile(filepath.Join(p.StateDir(), name))
+	if err != nil {
+		Trace(funcName, "error: end")
+		return "", fmt.Errorf("could not read state %s: %w", name, err)
+	}
+	Debug(funcName, "%s: %s", name, value)
+
+	Trace(funcName, "end")
+	return strings.TrimSpace(string(value)), nil
+}
+
+// WriteState writes a named value to the project's state directory.
+func (p *Project) WriteState(name string, value string) error {
+	funcName := "cmn.WriteState"
+	Trace(funcName, "begin")
+	Debug(funcName, "%s: %s", name, value)
+
+	err := os.MkdirAll(p.StateDir(), 0755)
+	if err != nil {
+		Trace(funcName, "error: end")
+		return fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	err = os.WriteFile(filepath.Join(p.StateDir(), name), []byte(value+"\n"), 0644)
+	if err != nil {
+		Trace(funcName, "error: end")
+		return fmt.Errorf("could not write state %s: %w", name, err)
 	}
+
+	Trace(funcName, "end")
+	return nil
+}
+
+// CommandLine returns the command line of the program, for naming it to the
+// user, e.g. in the project's lock file.
+func CommandLine() string {
+	return strings.Join(append([]string{filepath.Base(os.Args[0])}, os.Args[1:]...), " ")
+}
+
+// RequestCd asks the shell integration to change directory to path once the
+// program exits. It returns false if the shell integration is not active.
+func RequestCd(path string) (bool, error) {
+	funcName := "cmn.RequestCd"
+	Trace(funcName, "begin")
+
+	cdFile := os.Getenv(CdFileEnv)
+	if cdFile == "" {
+		Trace(funcName, "shell integration inactive: end")
+		return false, nil
+	}
+	Debug(funcName, "cd file: %s", cdFile)
+
+	err := os.WriteFile(cdFile, []byte(path), 0600)
+	if err != nil {
+		Trace(funcName, "error: end")
+		return false, fmt.Errorf("could not write cd file: %w", err)
+	}
+
+	Trace(funcName, "end")
+	return true, nil
 }
 
 // WriteConfig writes program's config file to cloned repo's project path.
 func WriteConfig(path string, branch string) error {
 	funcName := "cmn.WriteConfig"
-	Debug("%s: begin", funcName)
+	Trace(funcName, "begin")
 
 	// Set the configuration file name.
 	filename := filepath.Join(path, "."+Basename)
-	Debug("%s: config filename: %s", funcName, filename)
+	Debug(funcName, "config filename: %s", filename)
 
 	// Open the configuration file to write the config.
 	cfgFile, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
 	if err != nil {
-		Debug("%s: error: end", funcName)
-		return fmt.Errorf("could not create config file: %v", err.Error())
+		Trace(funcName, "error: end")
+		return fmt.Errorf("could not create config file: %w", err)
 	}
 	defer cfgFile.Close()
-	Debug("%s: config file opened for writing", funcName)
+	Debug(funcName, "config file opened for writing")
 
 	// Write the configuration to the file.
 	cfg := fmt.Sprintf("default: %s", branch)
-	fmt.Fprintf(cfgFile, "%s", cfg)
-	Debug("%s: config written to file> %s", funcName, cfg)
+	fmt.Fprintf(cfgFile, "%s\n", cfg)
+	Debug(funcName, "config written to file> %s", cfg)
+
+	Trace(funcName, "end")
+	return nil
+}
+
+// UpdateConfig rewrites the values of the project's config file through
+// update, keeping comments, unknown lines and order, and replaces the file
+// atomically. A key update returns unchanged is left as it was.
+func (p *Project) UpdateConfig(update func(key string, value string) string) error {
+	funcName := "cmn.UpdateConfig"
+	Trace(funcName, "begin")
+
+	filename := filepath.Join(p.ProjectDir, "."+Basename)
+	Debug(funcName, "config filename: %s", filename)
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		Trace(funcName, "error: end")
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	lines := strings.SplitAfter(string(contents), "\n")
+	for i, line := range lines {
+		key, value, found := strings.Cut(strings.TrimSuffix(line, "\n"), ":")
+		if !found {
+			continue
+		}
+		updated := update(strings.TrimSpace(key), strings.TrimSpace(value))
+		if updated != strings.TrimSpace(value) {
+			lines[i] = fmt.Sprintf("%s: %s\n", key, updated)
+			Debug(funcName, "updated line: %s", strings.TrimSpace(lines[i]))
+		}
+	}
 
-	Debug("%s: end", funcName)
+	// Write a temporary file beside the config and rename it into place.
+	temp, err := os.CreateTemp(p.ProjectDir, "."+Basename+".*")
+	if err != nil {
+		Trace(funcName, "error: end")
+		return fmt.Errorf("could not create config file: %w", err)
+	}
+	_, err = temp.WriteString(strings.Join(lines, ""))
+	if err == nil {
+		err = temp.Chmod(0644)
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		Trace(funcName, "error: end")
+		return fmt.Errorf("could not write config file: %w", err)
+	}
+
+	Trace(funcName, "end")
 	return nil
 }
 
 // FindConfig finds the program's config file.
-func findConfig(cwd string) (string, error) {
+func findConfig(cwd string) (string, string, error) {
 	funcName := "cmn.findConfig"
-	Debug("%s: begin", funcName)
+	Trace(funcName, "begin")
 
-	cfgFile, err := walkUpTree(cwd)
+	cfgFile, projectDir, err := walkUpTree(cwd)
 	if err != nil {
-		Debug("%s: error: end", funcName)
-		return "", fmt.Errorf("no project config file found")
+		Trace(funcName, "error: end")
+		return "", "", fmt.Errorf("%w: no %s file in %s or its parents; create a project with '%s cl'", ErrNoProject, "."+Basename, cwd, Basename)
 	}
 
-	Debug("%s: end", funcName)
-	return cfgFile, nil
+	Trace(funcName, "end")
+	return cfgFile, projectDir, nil
 }
 
-// walkUpTree walks up the filesystem tree until the config file is found.
-func walkUpTree(path string) (string, error) {
+// walkUpTree walks up the filesystem tree until the config file is found,
+// returning the file and the project directory holding it.
+func walkUpTree(path string) (string, string, error) {
 	funcName := "cmn.walkUpTree"
-	Debug("%s: begin", funcName)
+	Trace(funcName, "begin")
 
 	target := filepath.Clean(path)
 	for {
 		filename := filepath.Join(target, "."+Basename)
-		Debug("%s: checking %s", funcName, filename)
+		Debug(funcName, "checking %s", filename)
 
 		_, err := os.Stat(filename)
 		if err != nil {
 			target, _ = filepath.Split(target)
 			target = filepath.Clean(target)
 			if target == "/" {
-				Debug("%s: error: end", funcName)
-				return "", fmt.Errorf("no project config file")
+				Trace(funcName, "error: end")
+				return "", "", fmt.Errorf("no project config file")
 			}
 			continue
 		} else {
-			Config.ProjectDir = target
-			Debug("%s: project dir: %s", funcName, Config.ProjectDir)
-			Debug("%s: end", funcName)
-			return filename, nil
+			Debug(funcName, "project dir: %s", target)
+			Trace(funcName, "end")
+			return filename, target, nil
 		}
 	}
 }
 
-// InitConfig locates and reads the configuration file for use.
-func InitConfig() error {
-	funcName := "cmn.InitConfig"
-	Debug("%s: begin", funcName)
+// OpenProject locates and reads the configuration of the project holding dir.
+func OpenProject(dir string) (*Project, error) {
+	funcName := "cmn.OpenProject"
+	Trace(funcName, "begin")
 
-	// Get the current working directory.
-	cwd, err := os.Getwd()
-	if err != nil {
-		Debug("%s: error: end", funcName)
-		return fmt.Errorf("%s: error locating working directory: %s", funcName, err.Error())
-	}
-	Config.InitialDir = cwd
-	Debug("%s: set initial dir: %s", funcName, Config.InitialDir)
+	cfg := &Project{}
+	cfg.InitialDir = filepath.Clean(dir)
+	Debug(funcName, "set initial dir: %s", cfg.InitialDir)
 
 	// Locate the config file.
-	cfgFile, err := findConfig(Config.InitialDir)
+	cfgFile, projectDir, err := findConfig(cfg.InitialDir)
 	if err != nil {
-		Debug("%s: error: end\n", funcName)
-		return fmt.Errorf("%s: error locating config: %s", funcName, err.Error())
+		Trace(funcName, "error: end")
+		return nil, err
 	}
-	Debug("%s: config file: %s", funcName, cfgFile)
+	cfg.ProjectDir = projectDir
+	Debug(funcName, "config file: %s", cfgFile)
 
 	// Read the config file.
 	file, err := os.ReadFile(cfgFile)
 	if err != nil {
-		Debug("%s: error: end", funcName)
-		return fmt.Errorf("%s: error reading config file: %s", funcName, err.Error())
+		Trace(funcName, "error: end")
+		return nil, fmt.Errorf("error reading %s: %w", cfgFile, err)
 	}
-	Debug("%s: config file length: %d", funcName, len(file))
-	scanner := bufio.NewScanner(strings.NewReader(string(file)))
+	Debug(funcName, "config file length: %d", len(file))
+	err = parseConfig(string(file), cfg)
+	if err != nil {
+		Trace(funcName, "error: end")
+		return nil, fmt.Errorf("error parsing %s: %w", cfgFile, err)
+	}
+	Debug(funcName, "default branch: %s", cfg.DefaultBranch)
+	Debug(funcName, "remote: %s", cfg.Remote)
+
+	Trace(funcName, "end")
+	return cfg, nil
+}
+
+// parseConfig parses the "key: value" lines of the config file into cfg.
+func parseConfig(contents string, cfg *Project) error {
+	funcName := "cmn.parseConfig"
+	Trace(funcName, "begin")
+
+	// Set defaults for optional settings.
+	cfg.Remote = DefaultRemote
+	cfg.Fetch = false
+	cfg.MvBranch = false
+	cfg.Slashes = SlashesNest
+	cfg.HookFailure = HookFailureAbort
+	cfg.Hooks = map[string][]string{}
+	cfg.Copy = []string{}
+	cfg.CopyMode = CopyModeCopy
+	cfg.Picker = PickerBuiltin
+
+	scanner := bufio.NewScanner(strings.NewReader(contents))
 	for scanner.Scan() {
 		line := scanner.Text()
-		Debug("%s: config file line: %s", funcName, line)
-		if strings.HasPrefix(line, "default: ") {
-			Config.DefaultBranch = strings.Split(line, " ")[1]
-			break
-		} else {
-			Debug("%s: invalid config line; skipping: %s", funcName, line)
+		Debug(funcName, "config file line: %s", line)
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			Debug(funcName, "invalid config line; skipping: %s", line)
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "default":
+			cfg.DefaultBranch = value
+		case "remote":
+			cfg.Remote = value
+		case "fetch":
+			fetch, err := strconv.ParseBool(value)
+			if err != nil {
+				Trace(funcName, "error: end")
+				return fmt.Errorf("invalid value for fetch: %s", value)
+			}
+			cfg.Fetch = fetch
+		case "mv-branch":
+			mvBranch, err := strconv.ParseBool(value)
+			if err != nil {
+				Trace(funcName, "error: end")
+				return fmt.Errorf("invalid value for mv-branch: %s", value)
+			}
+			cfg.MvBranch = mvBranch
+		case "slashes":
+			switch value {
+			case SlashesNest, SlashesFlatten, SlashesReject:
+				cfg.Slashes = value
+			default:
+				Trace(funcName, "error: end")
+				return fmt.Errorf("invalid value for slashes: %s", value)
+			}
+		case "copy":
+			cfg.Copy = append(cfg.Copy, value)
+		case "copy-mode":
+			switch value {
+			case CopyModeCopy, CopyModeHardlink, CopyModeReflink, CopyModeSymlink:
+				cfg.CopyMode = value
+			default:
+				Trace(funcName, "error: end")
+				return fmt.Errorf("invalid value for copy-mode: %s", value)
+			}
+		case "picker":
+			switch value {
+			case PickerBuiltin, PickerFzf:
+				cfg.Picker = value
+			default:
+				Trace(funcName, "error: end")
+				return fmt.Errorf("invalid value for picker: %s", value)
+			}
+		case "hook-failure":
+			switch value {
+			case HookFailureAbort, HookFailureWarn:
+				cfg.HookFailure = value
+			default:
+				Trace(funcName, "error: end")
+				return fmt.Errorf("invalid value for hook-failure: %s", value)
+			}
+		default:
+			if slices.Contains(HookNames, key) {
+				cfg.Hooks[key] = append(cfg.Hooks[key], value)
+			} else {
+				Debug(funcName, "unknown config key; skipping: %s", key)
+			}
 		}
 	}
-	Debug("%s: default branch: %s", funcName, Config.DefaultBranch)
 
-	Debug("%s: end", funcName)
+	if cfg.DefaultBranch == "" {
+		Trace(funcName, "error: end")
+		return fmt.Errorf("no default branch in config file")
+	}
+
+	Trace(funcName, "end")
 	return nil
 }
+
+// invalidNameRune reports whether a rune is not allowed in a worktree name.
+func invalidNameRune(r rune) bool {
+	return r < 0x20 || r == 0x7f || strings.ContainsRune(`\:*?"<>|`, r)
+}
+
+// WorktreeName validates a worktree name and sanitizes it for use as a path
+// relative to the project directory, applying the configured slash policy.
+func (p *Project) WorktreeName(name string) (string, error) {
+	funcName := "cmn.WorktreeName"
+	Trace(funcName, "begin")
+	Debug(funcName, "name: %s", name)
+
+	// Normalize separators and replace whitespace runs with '-'.
+	clean := strings.Join(strings.Fields(filepath.ToSlash(name)), "-")
+	if clean == "" {
+		Trace(funcName, "error: end")
+		return "", fmt.Errorf("%w: empty", ErrInvalidName)
+	}
+
+	if strings.HasPrefix(clean, "/") || filepath.IsAbs(name) {
+		Trace(funcName, "error: end")
+		return "", fmt.Errorf("%w: must be relative to project: %s", ErrInvalidName, name)
+	}
+
+	// Apply the slash policy.
+	if strings.Contains(clean, "/") {
+		switch p.Slashes {
+		case SlashesFlatten:
+			clean = strings.ReplaceAll(clean, "/", "-")
+		case SlashesReject:
+			Trace(funcName, "error: end")
+			return "", fmt.Errorf("%w: slashes not allowed: %s", ErrInvalidName, name)
+		}
+	}
+
+	// Check each path component.
+	for _, v := range strings.Split(clean, "/") {
+		switch {
+		case v == "":
+			Trace(funcName, "error: end")
+			return "", fmt.Errorf("%w: empty path component: %s", ErrInvalidName, name)
+		case v == "." || v == "..":
+			Trace(funcName, "error: end")
+			return "", fmt.Errorf("%w: relative path component: %s", ErrInvalidName, name)
+		case strings.HasPrefix(v, "."):
+			Trace(funcName, "error: end")
+			return "", fmt.Errorf("%w: component starts with '.': %s", ErrInvalidName, name)
+		case strings.HasPrefix(v, "-"):
+			Trace(funcName, "error: end")
+			return "", fmt.Errorf("%w: component starts with '-': %s", ErrInvalidName, name)
+		case strings.ContainsFunc(v, invalidNameRune):
+			Trace(funcName, "error: end")
+			return "", fmt.Errorf("%w: invalid character: %s", ErrInvalidName, name)
+		}
+	}
+
+	clean = filepath.FromSlash(clean)
+	Debug(funcName, "sanitized name: %s", clean)
+
+	Trace(funcName, "end")
+	return clean, nil
+}
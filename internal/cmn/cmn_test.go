@@ -0,0 +1,62 @@
+package cmn
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorktreeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		slashes string // Slash policy of the project.
+		input   string // Worktree name given.
+		want    string // Sanitized name, in slash form; empty if rejected.
+	}{
+		{name: "plain", slashes: SlashesNest, input: "feature", want: "feature"},
+		{name: "nest", slashes: SlashesNest, input: "feature/one", want: "feature/one"},
+		{name: "nest deeper", slashes: SlashesNest, input: "a/b/c", want: "a/b/c"},
+		{name: "flatten", slashes: SlashesFlatten, input: "feature/one", want: "feature-one"},
+		{name: "flatten without slashes", slashes: SlashesFlatten, input: "feature", want: "feature"},
+		{name: "reject", slashes: SlashesReject, input: "feature/one"},
+		{name: "reject without slashes", slashes: SlashesReject, input: "feature", want: "feature"},
+		{name: "whitespace", slashes: SlashesNest, input: "my  new\tname", want: "my-new-name"},
+		{name: "surrounding whitespace", slashes: SlashesNest, input: "  name \n", want: "name"},
+		{name: "whitespace in component", slashes: SlashesNest, input: "a b/c d", want: "a-b/c-d"},
+		{name: "empty", slashes: SlashesNest, input: ""},
+		{name: "only whitespace", slashes: SlashesNest, input: " \t "},
+		{name: "leading dot", slashes: SlashesNest, input: ".hidden"},
+		{name: "leading dot in component", slashes: SlashesNest, input: "a/.hidden"},
+		{name: "leading dash", slashes: SlashesNest, input: "-f"},
+		{name: "leading dash in component", slashes: SlashesNest, input: "a/-f"},
+		{name: "leading dash after whitespace", slashes: SlashesNest, input: "a / b"},
+		{name: "dot", slashes: SlashesNest, input: "."},
+		{name: "dot dot", slashes: SlashesNest, input: "a/../b"},
+		{name: "empty component", slashes: SlashesNest, input: "a//b"},
+		{name: "trailing slash", slashes: SlashesNest, input: "a/"},
+		{name: "absolute", slashes: SlashesNest, input: "/abs"},
+		{name: "absolute flattened", slashes: SlashesFlatten, input: "/abs"},
+		{name: "invalid character", slashes: SlashesNest, input: "a:b"},
+		{name: "control character", slashes: SlashesNest, input: "a\x7fb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &Project{Slashes: tt.slashes}
+
+			got, err := project.WorktreeName(tt.input)
+
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidName) {
+					t.Errorf("WorktreeName(%q) = %q, %v; want ErrInvalidName", tt.input, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WorktreeName(%q): %v", tt.input, err)
+			}
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("WorktreeName(%q) = %q, want %q", tt.input, got, filepath.FromSlash(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package cmn
+
+import (
+	"errors"
+)
+
+var (
+	ErrUsage          = errors.New("invalid usage")                             // Invalid flags or arguments.
+	ErrInvalidName    = errors.New("invalid worktree name")                     // Worktree name rejected by the project's rules.
+	ErrNoProject      = errors.New("not in a " + Basename + " project")         // No config file in the directory or its parents.
+	ErrNoWorktree     = errors.New("no such worktree")                          // No worktree of the project by a name or query.
+	ErrWorktreeDirty  = errors.New("worktree has uncommitted changes")          // Worktree needs forcing to be removed.
+	ErrWorktreeLocked = errors.New("worktree is locked")                        // Worktree needs forcing twice to be removed or moved.
+	ErrInsideWorktree = errors.New("current working directory within worktree") // Worktree to remove or move holds the working directory.
+	ErrExists         = errors.New("already exists")                            // Project, worktree or branch to create exists.
+	ErrRemote         = errors.New("remote error")                              // Remote could not be reached or read.
+	ErrProjectLocked  = errors.New("project is locked")                         // Project is locked by another process changing it.
+	ErrTimeout        = errors.New("timed out")                                 // Command ran past its --timeout.
+	ErrInterrupted    = errors.New("interrupted")                               // Command was interrupted, e.g. by Ctrl-C.
+)
+
+const (
+	ExitError         = 1  // Any failure not listed below.
+	ExitUsage         = 2  // ErrUsage or ErrInvalidName.
+	ExitNoProject     = 3  // ErrNoProject.
+	ExitNoWorktree    = 4  // ErrNoWorktree.
+	ExitWorktreeDirty = 5  // ErrWorktreeDirty.
+	ExitLocked        = 6  // ErrWorktreeLocked.
+	ExitInside        = 7  // ErrInsideWorktree.
+	ExitExists        = 8  // ErrExists.
+	ExitRemote        = 9  // ErrRemote.
+	ExitProjectLocked = 10 // ErrProjectLocked.
+
+	ExitTimeout     = 124 // ErrTimeout; as timeout(1) exits.
+	ExitInterrupted = 130 // ErrInterrupted; as shells report SIGINT.
+)
+
+// ExitCode returns the exit code for a command failing with err; 0 if err is
+// nil.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrInterrupted):
+		return ExitInterrupted
+	case errors.Is(err, ErrTimeout):
+		return ExitTimeout
+	case errors.Is(err, ErrUsage), errors.Is(err, ErrInvalidName):
+		return ExitUsage
+	case errors.Is(err, ErrNoProject):
+		return ExitNoProject
+	case errors.Is(err, ErrNoWorktree):
+		return ExitNoWorktree
+	case errors.Is(err, ErrWorktreeDirty):
+		return ExitWorktreeDirty
+	case errors.Is(err, ErrWorktreeLocked):
+		return ExitLocked
+	case errors.Is(err, ErrInsideWorktree):
+		return ExitInside
+	case errors.Is(err, ErrExists):
+		return ExitExists
+	case errors.Is(err, ErrRemote):
+		return ExitRemote
+	case errors.Is(err, ErrProjectLocked):
+		return ExitProjectLocked
+	}
+	return ExitError
+}
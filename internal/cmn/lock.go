@@ -0,0 +1,187 @@
+package cmn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type (
+	LockHolder struct {
+		PID     int       `json:"pid"`     // Process id of the holder.
+		Host    string    `json:"host"`    // Host the holder runs on.
+		Command string    `json:"command"` // Command line of the holder.
+		Since   time.Time `json:"since"`   // When the lock was taken.
+	} // Process holding the lock of a project, as recorded in the lock file.
+)
+
+const (
+	LockFile = "lock" // Name of the lock file in the project's state directory.
+
+	lockPoll  = 100 * time.Millisecond // Interval between attempts while waiting for the lock.
+	lockGrace = 10 * time.Second       // Age after which an unreadable lock file is stale.
+)
+
+// LockPath returns the path of the project's lock file.
+func (p *Project) LockPath() string {
+	return filepath.Join(p.StateDir(), LockFile)
+}
+
+// Lock takes the project's advisory lock, which every command changing the
+// project holds while it runs, waiting up to wait for another process to
+// release it. A lock left behind by a process that no longer runs on this
+// host is stale and taken over. The returned function releases the lock.
+//
+// The lock is not reentrant: a second Lock in the same process waits like
+// any other. It follows the project if ProjectDir changes while it is held,
+// as when the project directory is moved.
+func (p *Project) Lock(ctx context.Context, wait time.Duration) (func(), error) {
+	funcName := "cmn.Lock"
+	Trace(funcName, "begin")
+
+	err := os.MkdirAll(p.StateDir(), 0755)
+	if err != nil {
+		Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	path := p.LockPath()
+	host, _ := os.Hostname()
+	holder := LockHolder{
+		PID:     os.Getpid(),
+		Host:    host,
+		Command: CommandLine(),
+		Since:   time.Now().Truncate(time.Second),
+	}
+	content, err := json.Marshal(holder)
+	if err != nil {
+		Trace(funcName, "error: end")
+		return nil, err
+	}
+	content = append(content, '\n')
+
+	deadline := time.Now().Add(wait)
+	for {
+		if err := context.Cause(ctx); err != nil {
+			Trace(funcName, "error: end")
+			return nil, err
+		}
+
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.Write(content)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				Trace(funcName, "error: end")
+				return nil, fmt.Errorf("could not write lock file: %w", err)
+			}
+			Debug(funcName, "locked: %s", path)
+
+			Trace(funcName, "end")
+			return func() { unlock(p.LockPath(), content) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			Trace(funcName, "error: end")
+			return nil, fmt.Errorf("could not create lock file: %w", err)
+		}
+
+		// Take over a stale lock, unless it changed hands meanwhile.
+		held, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			Trace(funcName, "error: end")
+			return nil, fmt.Errorf("could not read lock file: %w", err)
+		}
+		other, stale := staleLock(path, held)
+		if stale {
+			Debug(funcName, "removing stale lock: %s", held)
+			takeOver(path)
+			continue
+		}
+
+		if !time.Now().Before(deadline) {
+			Trace(funcName, "error: end")
+			if other.PID == 0 {
+				return nil, fmt.Errorf("%w; lock file %s", ErrProjectLocked, path)
+			}
+			return nil, fmt.Errorf("%w by PID %d on %s (%s) since %s; lock file %s",
+				ErrProjectLocked, other.PID, other.Host, other.Command, other.Since.Local().Format(time.DateTime), path)
+		}
+		Debug(funcName, "waiting for lock held by PID %d", other.PID)
+		select {
+		case <-ctx.Done():
+		case <-time.After(min(lockPoll, time.Until(deadline))):
+		}
+	}
+}
+
+// staleLock parses the content of a lock file, reporting whether it was left
+// behind by a process no longer running on this host, or is unreadable and
+// older than lockGrace.
+func staleLock(path string, content []byte) (LockHolder, bool) {
+	holder := LockHolder{}
+	if err := json.Unmarshal(content, &holder); err != nil || holder.PID <= 0 {
+		// Being written, or left half-written.
+		info, err := os.Stat(path)
+		return LockHolder{}, err == nil && time.Since(info.ModTime()) > lockGrace
+	}
+
+	host, _ := os.Hostname()
+	if holder.Host != host {
+		return holder, false
+	}
+	return holder, !processAlive(holder.PID)
+}
+
+// takeOver removes a stale lock file. The file is first renamed to a name of
+// its own, so another process cannot replace it between checking and
+// removing; if it turns out to have changed hands meanwhile, it is put back,
+// unless yet another process took the lock since.
+func takeOver(path string) {
+	funcName := "cmn.takeOver"
+	Trace(funcName, "begin")
+
+	taken := fmt.Sprintf("%s.%d-%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, taken); err != nil {
+		Trace(funcName, "end")
+		return
+	}
+	defer os.Remove(taken)
+
+	content, err := os.ReadFile(taken)
+	if err != nil {
+		Trace(funcName, "end")
+		return
+	}
+	if _, stale := staleLock(taken, content); !stale {
+		Debug(funcName, "lock changed hands, putting it back: %s", content)
+		os.Link(taken, path)
+	}
+
+	Trace(funcName, "end")
+}
+
+// unlock removes the lock file if it still holds content.
+func unlock(path string, content []byte) {
+	funcName := "cmn.unlock"
+	Trace(funcName, "begin")
+
+	current, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(current, content) {
+		os.Remove(path)
+		Debug(funcName, "unlocked: %s", path)
+	}
+
+	Trace(funcName, "end")
+}
@@ -0,0 +1,53 @@
+package cmn
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTakeOver(t *testing.T) {
+	host, _ := os.Hostname()
+	tests := []struct {
+		name   string
+		holder LockHolder // Holder recorded in the lock file when it is taken over.
+		stale  bool       // Whether the lock file is removed.
+	}{
+		{name: "dead process", holder: LockHolder{PID: 0x7ffffff0, Host: host}, stale: true},
+		{name: "live process", holder: LockHolder{PID: os.Getpid(), Host: host}},
+		{name: "other host", holder: LockHolder{PID: 0x7ffffff0, Host: host + "-other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, LockFile)
+			content, err := json.Marshal(tt.holder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			takeOver(path)
+
+			got, err := os.ReadFile(path)
+			if tt.stale && err == nil {
+				t.Errorf("stale lock file not removed: %s", got)
+			}
+			if !tt.stale && string(got) != string(content) {
+				t.Errorf("lock file = %q, %v; want it put back as %q", got, err, content)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range entries {
+				if v.Name() != LockFile {
+					t.Errorf("renamed lock file left behind: %s", v.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package cmn
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	LevelTrace = slog.LevelDebug - 4 // Level of the begin and end steps of functions.
+
+	LogFormatText = "text" // Log records as key=value lines.
+	LogFormatJSON = "json" // Log records as JSON objects, one per line.
+)
+
+var (
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})) // Logger of the program; see SetupLog.
+)
+
+// ParseLogLevel parses a log level name: trace, debug, info, warn or error.
+func ParseLogLevel(name string) (slog.Level, error) {
+	if strings.EqualFold(name, "trace") {
+		return LevelTrace, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("%w: invalid log level, use trace, debug, info, warn or error: %s", ErrUsage, name)
+	}
+	return level, nil
+}
+
+// SetupLog sets the level and format of the program's log, which goes to
+// stderr, or is appended to the file named by GIT_WT_LOG_FILE if set.
+func SetupLog(levelName string, format string) error {
+	level, err := ParseLogLevel(levelName)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stderr
+	if path := os.Getenv(LogFileEnv); path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open log file: %w", err)
+		}
+		w = file
+	}
+
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			// Name the trace level, which slog would show as DEBUG-4.
+			if attr.Key == slog.LevelKey && len(groups) == 0 && attr.Value.Any() == LevelTrace {
+				attr.Value = slog.StringValue("TRACE")
+			}
+			return attr
+		},
+	}
+	switch format {
+	case LogFormatText:
+		logger = slog.New(slog.NewTextHandler(w, opts))
+	case LogFormatJSON:
+		logger = slog.New(slog.NewJSONHandler(w, opts))
+	default:
+		return fmt.Errorf("%w: invalid log format, use text or json: %s", ErrUsage, format)
+	}
+
+	return nil
+}
+
+// Logger returns the program's logger.
+func Logger() *slog.Logger {
+	return logger
+}
+
+// Trace logs a step of a function, such as "begin" or "end", at the trace
+// level.
+func Trace(funcName string, step string) {
+	logger.Log(context.Background(), LevelTrace, step, "func", funcName)
+}
+
+// Debug logs a message of a function at the debug level, formatting it like
+// fmt.Sprintf only if debug logging is enabled.
+func Debug(funcName string, format string, args ...any) {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	logger.Debug(fmt.Sprintf(format, args...), "func", funcName)
+}
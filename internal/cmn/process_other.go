@@ -0,0 +1,18 @@
+//go:build !unix
+
+package cmn
+
+import (
+	"os"
+)
+
+// processAlive reports whether a process with the id runs on this host; on
+// Windows, finding a process fails if it does not run.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
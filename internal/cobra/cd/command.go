@@ -0,0 +1,84 @@
+// Package cd implements the cd subcommand for git-wt.
+package cd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "cd" // Command name.
+)
+
+// New returns the cobra command for 'cd'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     command + " [worktree_name|-]",
+		Short:   "Change to a worktree of the project.",
+		Long:    cmn.Basename + " " + command + " - Change to a worktree of the project.\n\nThe worktree may be given by name, branch, prefix or fuzzy match, as '-' for\nthe previous worktree, or picked interactively if omitted. With shell\nintegration active (see 'shell-init') the shell changes directory; otherwise,\nand when called as 'path', the absolute path of the worktree is printed.",
+		Args:    cobra.RangeArgs(0, 1),
+		Aliases: []string{"path"},
+		RunE:    run,
+	} // Cobra command definition for the 'cd' command.
+
+	return cmd
+}
+
+// run is the main function for the 'cd' command.
+func run(cmd *cobra.Command, args []string) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+	cmn.Debug(funcName, "args: %v", args)
+
+	// Resolve the worktree.
+	worktree := wt.Worktree{}
+	switch {
+	case len(args) == 0:
+		worktree, err = project.Pick(ctx, command+"> ")
+	case args[0] == "-":
+		worktree, err = project.Previous(ctx)
+	default:
+		worktree, err = project.Find(ctx, args[0])
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	cmn.Debug(funcName, "worktree: %s", worktree.Path)
+
+	// Print the path only.
+	if cmd.CalledAs() == "path" {
+		fmt.Println(worktree.Path)
+		cmn.Trace(funcName, "end")
+		return nil
+	}
+
+	// Change to the worktree.
+	ok, err := project.Switch(ctx, worktree.Path)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	if !ok {
+		fmt.Println(worktree.Path)
+		fmt.Fprintf(os.Stderr, "shell integration inactive; see '%s shell-init --help'\n", cmn.Basename)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
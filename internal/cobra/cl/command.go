@@ -3,17 +3,20 @@ package cl
 
 import (
 	"fmt"
-	"path/filepath"
-	"strings"
+	"os"
 
 	"github.com/jason-dour/git-wt/internal/cmn"
-	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/pkg/wt"
 	"github.com/spf13/cobra"
 )
 
 var (
 	command = "cl" // Command name.
-	Cmd     = &cobra.Command{
+)
+
+// New returns the cobra command for 'clone'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
 		Use:     command + " repo_url",
 		Short:   "Clone a repo for a git-wt workflow.",
 		Long:    cmn.Basename + " " + command + " - Clone a repo for a git-wt workflow.",
@@ -21,53 +24,27 @@ var (
 		Aliases: []string{"clone"},
 		RunE:    run,
 	} // Cobra command definition for the 'clone' command.
-)
+
+	return cmd
+}
 
 // run is the main function for the 'cl' command.
 func run(cmd *cobra.Command, args []string) error {
-	funcName := "run"
-	cmn.Debug("%s: %s: begin", command, funcName)
-	cmn.Debug("%s: %s: args: %v", command, funcName, args)
-
-	fmt.Printf("Cloning %s.\n", args[0])
-
-	// Get default branch from remote repository.
-	cmn.Debug("%s: %s: retrieving default branch from remote", command, funcName)
-	defaultBranch, err := git.GetRemoteDefaultBranch(args[0])
-	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("could not retrieve default branch: %v", err.Error())
-	}
-	cmn.Debug("%s: run: defaultBranch: %v", command, defaultBranch)
-
-	// Define clone path.
-	repo := ""
-	clonePath := ""
-	basename := filepath.Base(args[0])
-
-	if strings.HasSuffix(basename, ".git") {
-		repo = strings.TrimSuffix(basename, ".git")
-	} else {
-		repo = basename
-	}
-	clonePath = filepath.Join(repo, defaultBranch)
-
-	// Clone the repository.
-	err = git.Clone(args[0], defaultBranch, clonePath)
-	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("could not clone repo: %v", err.Error())
-	}
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "args: %v", args)
 
-	// Write config file to project path.
-	err = cmn.WriteConfig(repo, defaultBranch)
+	// Clone the repository into a new project.
+	lockWait, _ := cmd.Flags().GetDuration(cmn.LockWaitFlag)
+	project, err := wt.Clone(cmd.Context(), args[0], wt.CloneOptions{LockWait: lockWait, Out: os.Stdout})
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("error writing config file: %v", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return err
 	}
+	cmn.Debug(funcName, "project: %#v", project)
 
 	fmt.Printf("Clone complete.\n")
 
-	cmn.Debug("%s: %s: end", command, funcName)
+	cmn.Trace(funcName, "end")
 	return nil
 }
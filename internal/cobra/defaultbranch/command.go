@@ -0,0 +1,70 @@
+// Package defaultbranch implements the default subcommand for git-wt.
+package defaultbranch
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "default" // Command name.
+)
+
+// New returns the cobra command for 'default'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   command,
+		Short: "Switch the project to the remote's default branch.",
+		Long: cmn.Basename + " " + command + " - Switch the project to the remote's default branch.\n\n" +
+			"Detects the default branch of the remote again, e.g. after it moved from\n" +
+			"master to main, renames the local default branch and the default worktree\n" +
+			"to match, tracks the new branch on the remote, fast-forwards it if the\n" +
+			"worktree is clean, and updates the ." + cmn.Basename + " file.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         run,
+	} // Cobra command definition for the 'default' command.
+
+	return cmd
+}
+
+// run is the main function for the 'default' command.
+func run(cmd *cobra.Command, args []string) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	cwd, err := project.SetDefault(cmd.Context(), wt.DefaultOptions{Out: os.Stdout})
+
+	// Follow the move in the shell, even if some links are still broken.
+	if cwd != "" {
+		ok, cdErr := cmn.RequestCd(cwd)
+		if cdErr != nil && err == nil {
+			err = cdErr
+		}
+		if cdErr == nil && !ok {
+			fmt.Printf("Your current directory moved; change to %s\n", cwd)
+		}
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
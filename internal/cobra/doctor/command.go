@@ -0,0 +1,73 @@
+// Package doctor implements the doctor subcommand for git-wt.
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "doctor" // Command name.
+)
+
+// New returns the cobra command for 'doctor'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   command,
+		Short: "Check the project for problems.",
+		Long: cmn.Basename + " " + command + " - Check the project for problems.\n\n" +
+			"Checks the git version, the ." + cmn.Basename + " file, the default worktree, the\n" +
+			"remote, that git's worktree list matches the project directory, that every\n" +
+			"worktree links back to the repository, and for stray directories. Problems\n" +
+			"with worktree links can usually be fixed with 'repair'.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE:         run,
+	} // Cobra command definition for the 'doctor' command.
+
+	return cmd
+}
+
+// run is the main function for the 'doctor' command.
+func run(cmd *cobra.Command, args []string) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
+
+	findings := []wt.Finding{wt.CheckGit(ctx)}
+
+	// Open the project; the remaining checks need it.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		findings = append(findings, wt.Finding{Level: wt.LevelFail, Check: "config", Detail: err.Error()})
+	} else {
+		cmn.Debug(funcName, "project config: %#v", project)
+		findings = append(findings, project.Doctor(ctx)...)
+	}
+
+	// Print the findings.
+	failed := 0
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, v := range findings {
+		if v.Level == wt.LevelFail {
+			failed++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", v.Level, v.Check, v.Detail)
+	}
+	table.Flush()
+
+	if failed > 0 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("found %d problems", failed)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
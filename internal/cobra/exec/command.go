@@ -0,0 +1,314 @@
+// Package exec implements the exec subcommand for git-wt.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	osexec "os/exec"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+type (
+	result struct {
+		worktree wt.Worktree   // Worktree the command ran in.
+		code     int           // Exit code of the command; -1 if it could not run.
+		err      error         // Error running the command.
+		duration time.Duration // Time the command took.
+	} // Result of running the command in a worktree.
+
+	prefixWriter struct {
+		mu     *sync.Mutex // Serializes writes to out across worktrees.
+		out    io.Writer   // Destination of the prefixed lines.
+		prefix string      // Prefix written before each line.
+		buf    []byte      // Partial line awaiting its newline.
+	} // Writer prefixing each line of output.
+)
+
+var (
+	command   = "exec"          // Command name.
+	outMu     = &sync.Mutex{}   // Serializes output lines.
+	waitDelay = 2 * time.Second // Wait for the output of a killed command before giving up on it.
+)
+
+// New returns the cobra command for 'exec', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgExec{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:   command + " [flags] -- command [args...]",
+		Short: "Run a command in every worktree of the project.",
+		Long: cmn.Basename + " " + command + " - Run a command in every worktree of the project.\n\n" +
+			"A single command argument is run with the shell; several are run directly.\n" +
+			"Filters select worktrees and may be repeated; all must match:\n\n" +
+			"  name=<glob>            worktree name, e.g. name=feature/*\n" +
+			"  branch=<glob>          checked out branch\n" +
+			"  status=dirty|clean     uncommitted changes\n\n" +
+			"A filter without '=' is a name glob.",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
+	} // Cobra command definition for the 'exec' command.
+
+	cmd.PersistentFlags().StringArrayVar(&config.Filters, "filter", nil, "select worktrees by name=, branch= or status=")
+	cmd.PersistentFlags().IntVarP(&config.Parallel, "parallel", "p", 1, "number of worktrees to run in at once")
+
+	return cmd
+}
+
+// Write writes complete lines to the destination with the prefix.
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.mu.Lock()
+		_, err := fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf[:i])
+		w.mu.Unlock()
+		if err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any partial line left in the buffer.
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		w.Write([]byte("\n"))
+	}
+}
+
+// checkFilters scans the filters for proper syntax.
+func checkFilters(config *cmn.CfgExec) error {
+	funcName := command + ".checkFilters"
+	cmn.Trace(funcName, "begin")
+
+	for _, v := range config.Filters {
+		key, value, found := strings.Cut(v, "=")
+		if !found {
+			key, value = "name", v
+		}
+		switch key {
+		case "name", "branch":
+			if _, err := path.Match(value, ""); err != nil {
+				cmn.Trace(funcName, "error: end")
+				return fmt.Errorf("%w: invalid filter glob: %s", cmn.ErrUsage, v)
+			}
+		case "status":
+			if value != "dirty" && value != "clean" {
+				cmn.Trace(funcName, "error: end")
+				return fmt.Errorf("%w: invalid filter status, use dirty or clean: %s", cmn.ErrUsage, v)
+			}
+		default:
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w: invalid filter, use name=, branch= or status=: %s", cmn.ErrUsage, v)
+		}
+	}
+
+	if config.Parallel < 1 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: parallel must be at least 1", cmn.ErrUsage)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// matches reports whether a worktree matches every filter.
+func matches(ctx context.Context, project *wt.Project, config *cmn.CfgExec, worktree wt.Worktree) (bool, error) {
+	for _, v := range config.Filters {
+		key, value, found := strings.Cut(v, "=")
+		if !found {
+			key, value = "name", v
+		}
+		switch key {
+		case "name":
+			if ok, _ := path.Match(value, worktree.Name); !ok {
+				return false, nil
+			}
+		case "branch":
+			if ok, _ := path.Match(value, worktree.BranchName()); !ok || worktree.Branch == "" {
+				return false, nil
+			}
+		case "status":
+			lines, err := project.Changes(ctx, worktree)
+			if err != nil {
+				return false, err
+			}
+			if (value == "dirty") != (len(lines) > 0) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// runIn runs the command in a worktree, prefixing its output. The command is
+// not started once ctx is done, and is killed if ctx is done while it runs.
+func runIn(ctx context.Context, project *wt.Project, worktree wt.Worktree, args []string) result {
+	funcName := command + ".runIn"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "worktree: %s; args: %v", worktree.Name, args)
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return result{worktree: worktree, code: -1, err: err}
+	}
+
+	var cmd *osexec.Cmd
+	if len(args) == 1 {
+		if runtime.GOOS == "windows" {
+			cmd = osexec.CommandContext(ctx, "cmd", "/C", args[0])
+		} else {
+			cmd = osexec.CommandContext(ctx, "sh", "-c", args[0])
+		}
+	} else {
+		cmd = osexec.CommandContext(ctx, args[0], args[1:]...)
+	}
+
+	stdout := &prefixWriter{mu: outMu, out: os.Stdout, prefix: "[" + worktree.Name + "] "}
+	stderr := &prefixWriter{mu: outMu, out: os.Stderr, prefix: "[" + worktree.Name + "] "}
+	cmd.Dir = worktree.Path
+	cmd.Env = append(os.Environ(),
+		"GIT_WT_PROJECT_DIR="+project.Dir,
+		"GIT_WT_WORKTREE="+worktree.Name,
+		"GIT_WT_WORKTREE_PATH="+worktree.Path,
+		"GIT_WT_BRANCH="+worktree.BranchName(),
+	)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+
+	start := time.Now()
+	err := cmd.Run()
+	stdout.Flush()
+	stderr.Flush()
+
+	res := result{worktree: worktree, duration: time.Since(start)}
+	if cause := context.Cause(ctx); err != nil && cause != nil {
+		res.code = -1
+		res.err = cause
+	} else if exitErr := (*osexec.ExitError)(nil); errors.As(err, &exitErr) {
+		res.code = exitErr.ExitCode()
+	} else if err != nil {
+		res.code = -1
+		res.err = err
+	}
+	cmn.Debug(funcName, "result: %#v", res)
+
+	cmn.Trace(funcName, "end")
+	return res
+}
+
+// run is the main function for the 'exec' command.
+func run(cmd *cobra.Command, args []string, config *cmn.CfgExec) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+
+	cmn.Debug(funcName, "config: %#v", config)
+	cmn.Debug(funcName, "args: %v", args)
+
+	// Check configuration.
+	err = checkFilters(config)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	// Select the worktrees to run in.
+	worktrees, err := project.List(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	selected := []wt.Worktree{}
+	for _, v := range worktrees {
+		if !v.InProject() || v.Bare {
+			continue
+		}
+		ok, err := matches(ctx, project, config, v)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		if ok {
+			selected = append(selected, v)
+		}
+	}
+	cmn.Debug(funcName, "selected %d worktrees", len(selected))
+	if len(selected) == 0 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("no worktrees match the filters")
+	}
+
+	// Run in the worktrees, at most config.Parallel at once.
+	results := make([]result, len(selected))
+	slots := make(chan struct{}, config.Parallel)
+	wg := sync.WaitGroup{}
+	for i, v := range selected {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			results[i] = runIn(ctx, project, v, args)
+			<-slots
+		}()
+	}
+	wg.Wait()
+
+	// Print the summary.
+	failed := 0
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "\nWORKTREE\tEXIT\tTIME")
+	for _, v := range results {
+		code := fmt.Sprint(v.code)
+		if v.err != nil {
+			code = "error: " + v.err.Error()
+		}
+		if v.code != 0 {
+			failed++
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\n", v.worktree.Name, code, v.duration.Round(time.Millisecond))
+	}
+	table.Flush()
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("stopped; command failed or did not run in %d of %d worktrees: %w", failed, len(results), err)
+	}
+	if failed > 0 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("command failed in %d of %d worktrees", failed, len(results))
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
@@ -0,0 +1,77 @@
+// Package lock implements the lock subcommand for git-wt.
+package lock
+
+import (
+	"fmt"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "lock" // Command name.
+)
+
+// New returns the cobra command for 'lock', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgLock{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:   command + " [worktree_name]",
+		Short: "Lock a worktree of the project.",
+		Long:  cmn.Basename + " " + command + " - Lock a worktree of the project.\n\nA locked worktree is not pruned, moved or removed unless forced twice, and\nis skipped by 'rm --merged' and 'xx'. The worktree is picked interactively if\nomitted.",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
+	} // Cobra command definition for the 'lock' command.
+
+	cmd.PersistentFlags().StringVar(&config.Reason, "reason", "", "reason for locking the worktree")
+
+	return cmd
+}
+
+// run is the main function for the 'lock' command.
+func run(cmd *cobra.Command, args []string, config *cmn.CfgLock) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+
+	cmn.Debug(funcName, "config: %#v", config)
+	cmn.Debug(funcName, "args: %v", args)
+
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	// Resolve the worktree, picking it if not given.
+	found := wt.Worktree{}
+	if len(args) == 0 {
+		found, err = project.Pick(ctx, command+"> ")
+	} else {
+		found, err = project.Find(ctx, args[0])
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	cmn.Debug(funcName, "worktree: %s", found.Name)
+
+	locked, err := project.Lock(ctx, found.Name, config.Reason)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	fmt.Printf("Locked worktree: %s\n", locked.Name)
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
@@ -5,13 +5,17 @@ import (
 	"fmt"
 
 	"github.com/jason-dour/git-wt/internal/cmn"
-	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/pkg/wt"
 	"github.com/spf13/cobra"
 )
 
 var (
 	command = "ls" // Command name.
-	Cmd     = &cobra.Command{
+)
+
+// New returns the cobra command for 'ls'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
 		Use:     command,
 		Short:   "List worktrees for the project.",
 		Long:    cmn.Basename + " " + command + " - List worktrees for the project.",
@@ -19,29 +23,58 @@ var (
 		Aliases: []string{"list"},
 		RunE:    run,
 	} // Cobra command definition for the 'ls' command.
-)
+
+	return cmd
+}
 
 // run is the main function for the 'ls' command.
 func run(cmd *cobra.Command, args []string) error {
-	funcName := "run"
-	cmn.Debug("%s: %s: begin", command, funcName)
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
 
-	// Load global configuration.
-	cmn.Debug("%s: %s: loading global config", command, funcName)
-	err := cmn.InitConfig()
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	wtProject, err := wt.Open(".")
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("error loading configuration: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
 	}
-	cmn.Debug("%s: %s: global config: %#v", command, funcName, cmn.Config)
+	cmn.Debug(funcName, "project: %#v", wtProject)
 
-	output, err := git.WorktreeList(false)
+	worktrees, err := wtProject.List(cmd.Context())
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("error listing worktrees: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	// Print the worktrees the way 'git worktree list --verbose' does.
+	width := 0
+	for _, v := range worktrees {
+		width = max(width, len(v.Path))
+	}
+	for _, v := range worktrees {
+		head := v.Head
+		if len(head) > 7 {
+			head = head[:7]
+		}
+		switch {
+		case v.Bare:
+			fmt.Printf("%-*s (bare)\n", width, v.Path)
+		case v.Detached:
+			fmt.Printf("%-*s %s (detached HEAD)\n", width, v.Path, head)
+		default:
+			fmt.Printf("%-*s %s [%s]\n", width, v.Path, head, v.BranchName())
+		}
+		if v.Locked && v.LockReason != "" {
+			fmt.Printf("\tlocked: %s\n", v.LockReason)
+		} else if v.Locked {
+			fmt.Printf("\tlocked\n")
+		}
+		if v.Prunable {
+			fmt.Printf("\tprunable\n")
+		}
 	}
-	fmt.Print(string(output))
 
-	cmn.Debug("%s: %s: end", command, funcName)
+	cmn.Trace(funcName, "end")
 	return nil
 }
@@ -3,127 +3,112 @@ package mk
 
 import (
 	"fmt"
-	"path/filepath"
-	"slices"
-	"strings"
+	"os"
 
 	"github.com/jason-dour/git-wt/internal/cmn"
-	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/pkg/wt"
 	"github.com/spf13/cobra"
 )
 
 var (
-	command            = "mk"         // Command name.
-	config  *cmn.CfgMk = &cmn.CfgMk{} // Configuration for the command.
-	Cmd                = &cobra.Command{
-		Use:     command + " worktree_name commit-ish",
+	command = "mk" // Command name.
+)
+
+// New returns the cobra command for 'mk', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgMk{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:     command + " worktree_name [commit-ish]",
 		Short:   "Add a worktree to the project.",
 		Long:    cmn.Basename + " " + command + " - Add a worktree to the project.",
-		Args:    cobra.ExactArgs(2),
+		Args:    cobra.RangeArgs(1, 2),
 		Aliases: []string{"make"},
-		RunE:    run,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
 	} // Cobra command definition for the 'mk' command.
-)
 
-// init performs initialization for the 'mk' command.
-func init() {
-	Cmd.PersistentFlags().BoolVar(&config.Track, "track", false, "set up tracking mode")
-	Cmd.PersistentFlags().StringVarP(&config.Branch, "b", "b", "", "create a new branch")
-	Cmd.PersistentFlags().StringVarP(&config.BranchReset, "B", "B", "", "create or reset a branch")
-	Cmd.PersistentFlags().BoolVar(&config.CheckoutNo, "no-checkout", false, "do not populate the new worktree")
-	Cmd.PersistentFlags().BoolVarP(&config.Force, "force", "f", false, "checkout <branch> even if already checked out in other worktree")
-	Cmd.PersistentFlags().BoolVarP(&config.Quiet, "quiet", "q", false, "suppress progress reporting")
+	cmd.PersistentFlags().BoolVar(&config.Track, "track", false, "set up tracking mode")
+	cmd.PersistentFlags().BoolVar(&config.Cd, "cd", false, "change to the new worktree; requires shell integration")
+	cmd.PersistentFlags().StringVarP(&config.Branch, "b", "b", "", "create a new branch")
+	cmd.PersistentFlags().StringVarP(&config.BranchReset, "B", "B", "", "create or reset a branch")
+	cmd.PersistentFlags().BoolVar(&config.CheckoutNo, "no-checkout", false, "do not populate the new worktree")
+	cmd.PersistentFlags().BoolVar(&config.CopyNo, "no-copy", false, "do not carry local files from the default worktree")
+	cmd.PersistentFlags().BoolVar(&config.Fetch, "fetch", false, "fetch the remote before resolving commit-ish")
+	cmd.PersistentFlags().BoolVar(&config.FromDefault, "from-default", false, "base the worktree on <remote>/<default branch>")
+	cmd.PersistentFlags().BoolVarP(&config.Force, "force", "f", false, "checkout <branch> even if already checked out in other worktree")
+	cmd.PersistentFlags().BoolVar(&config.Lock, "lock", false, "keep the new worktree locked")
+	cmd.PersistentFlags().StringVar(&config.LockReason, "reason", "", "reason for locking the new worktree")
+	cmd.PersistentFlags().BoolVarP(&config.Quiet, "quiet", "q", false, "suppress progress reporting")
+
+	return cmd
 }
 
-// checkConfig scans config for proper use of flags.
-func checkConfig() error {
-	funcName := "checkConfig"
-	cmn.Debug("%s: %s: begin", command, funcName)
+// run is the main function for the 'mk' command.
+func run(cmd *cobra.Command, args []string, config *cmn.CfgMk) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
 
-	cmn.Debug("%s: %s: check mutually exclusive branch flags", command, funcName)
-	if len(config.Branch) > 0 && len(config.BranchReset) > 0 {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("config: set branch with either -b or -B; don't use both")
-	}
+	ctx := cmd.Context()
 
-	cmn.Debug("%s: %s: check track has a new branch in flags", command, funcName)
-	if config.Track && !(len(config.Branch) > 0 || len(config.BranchReset) > 0) {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("config: track requires new branch via -b or -B")
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
 	}
+	cmn.Debug(funcName, "project config: %#v", project)
 
-	cmn.Debug("%s: %s: end", command, funcName)
-	return nil
-}
+	cmn.Debug(funcName, "config: %#v", config)
+	cmn.Debug(funcName, "args: %v", args)
 
-// run is the main function for the 'mk' command.
-func run(cmd *cobra.Command, args []string) error {
-	funcName := "run"
-	cmn.Debug("%s: %s: begin", command, funcName)
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
 
-	// Load global configuration.
-	cmn.Debug("%s: %s: loading global config", command, funcName)
-	err := cmn.InitConfig()
-	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("error loading configuration: %s", err.Error())
+	// Use the project default for fetch unless the flag was given.
+	if !cmd.Flag("fetch").Changed {
+		config.Fetch = project.Fetch
 	}
-	cmn.Debug("%s: %s: global config: %#v", command, funcName, cmn.Config)
-
-	cmn.Debug("%s: %s: config: %#v", command, funcName, config)
-	cmn.Debug("%s: %s: args: %v", command, funcName, args)
-
-	// Set the worktree name.
-	wtName := args[0]
-	cmn.Debug("%s: %s: worktree name: %s", command, funcName, wtName)
+	cmn.Debug(funcName, "fetch: %v", config.Fetch)
 
-	// Set the commit-ish to be used.
-	commitish := args[1]
-	cmn.Debug("%s: %s: commit-ish: %s", command, funcName, commitish)
+	commitish := ""
+	if len(args) > 1 {
+		commitish = args[1]
+	}
 
-	// Check configuration.
-	err = checkConfig()
+	// Add the worktree.
+	added, err := project.Add(ctx, args[0], wt.AddOptions{
+		Commitish:   commitish,
+		FromDefault: config.FromDefault,
+		Branch:      config.Branch,
+		BranchReset: config.BranchReset,
+		Track:       config.Track,
+		Force:       config.Force,
+		NoCheckout:  config.CheckoutNo,
+		NoCopy:      config.CopyNo,
+		Lock:        config.Lock,
+		LockReason:  config.LockReason,
+		Fetch:       config.Fetch,
+		Quiet:       config.Quiet,
+		Out:         os.Stdout,
+	})
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
+		cmn.Trace(funcName, "error: end")
 		return err
 	}
 
-	// Check if a PR/MR and grab ref.
-	prmr := []string{"pull/", "pr/", "merge-requests/", "mr/"}
-	if slices.ContainsFunc(prmr, func(s string) bool {
-		return strings.HasPrefix(commitish, s)
-	}) {
-		cmn.Debug("%s: %s: pr/mr ref specified; finding commit id", command, funcName)
-		url, err := git.GetRemote(filepath.Join(cmn.Config.ProjectDir, cmn.Config.DefaultBranch))
+	// Change to the new worktree.
+	if config.Cd {
+		ok, err := project.Switch(ctx, added.Path)
 		if err != nil {
-			cmn.Debug("%s: %s: error: end", command, funcName)
+			cmn.Trace(funcName, "error: end")
 			return err
 		}
-		cmn.Debug("%s: %s: remote: %s", command, funcName, url)
-
-		commitish = strings.Replace(commitish, "pr/", "pull/", -1)
-		commitish = strings.Replace(commitish, "mr/", "merge-requests/", -1)
-		if !strings.HasSuffix(commitish, "/head") {
-			commitish = commitish + "/head"
-		}
-		cmn.Debug("%s: %s: normalized ref: %s", command, funcName, commitish)
-
-		config.RefId, err = git.GetRemoteRefId(url, commitish)
-		if err != nil {
-			cmn.Debug("%s: %s: error: end", command, funcName)
-			return err
+		if !ok {
+			fmt.Fprintf(os.Stderr, "shell integration inactive; see '%s shell-init --help'\n", cmn.Basename)
 		}
-		cmn.Debug("%s: %s: found commit id for ref: %s", command, funcName, config.RefId)
-	}
-
-	// Add the worktree.
-	output, err := git.WorktreeAdd(config, wtName, commitish)
-	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return err
 	}
-	fmt.Print(string(output))
 
-	cmn.Debug("%s: %s: end", command, funcName)
+	cmn.Trace(funcName, "end")
 	return nil
 }
@@ -3,63 +3,90 @@ package mv
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/jason-dour/git-wt/internal/cmn"
-	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/pkg/wt"
 	"github.com/spf13/cobra"
 )
 
 var (
-	command            = "mv"         // Command name.
-	config  *cmn.CfgMv = &cmn.CfgMv{} // Configuration for the command.
-	Cmd                = &cobra.Command{
-		Use:     command + " worktree-name new-worktree-name",
+	command = "mv" // Command name.
+)
+
+// New returns the cobra command for 'mv', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgMv{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:     command + " [worktree-name] new-worktree-name",
 		Short:   "Move a worktree within the project.",
-		Long:    cmn.Basename + " " + command + " - Move a worktree within the project.",
-		Args:    cobra.ExactArgs(2),
+		Long:    cmn.Basename + " " + command + " - Move a worktree within the project.\n\nWith --branch, or 'mv-branch: true' in the config, the branch checked out in\nthe worktree is renamed to the new worktree name; the move is refused if that\nbranch exists. With --upstream the renamed branch tracks its namesake on the\nremote, or no upstream if there is none.",
+		Args:    cobra.RangeArgs(1, 2),
 		Aliases: []string{"move", "ren", "rename"},
-		RunE:    run,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
 	} // Cobra command definition for the 'mv' command.
-)
 
-// init performs initialization for the 'mv' command.
-func init() {
-	Cmd.PersistentFlags().BoolVarP(&config.Force, "force", "f", false, "force move even if worktree is dirty or locked")
+	cmd.PersistentFlags().BoolVarP(&config.Force, "force", "f", false, "force move onto the path of a missing worktree; unlock a locked worktree to move it")
+	cmd.PersistentFlags().BoolVar(&config.Branch, "branch", false, "rename the checked-out branch to the new worktree name")
+	cmd.PersistentFlags().BoolVar(&config.Upstream, "upstream", false, "with --branch, track the renamed branch on the remote")
+
+	return cmd
 }
 
 // run is the main function for the 'mv' command.
-func run(cmd *cobra.Command, args []string) error {
-	funcName := "run"
-	cmn.Debug("%s: %s: begin", command, funcName)
+func run(cmd *cobra.Command, args []string, config *cmn.CfgMv) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
 
-	// Load global configuration.
-	cmn.Debug("%s: %s: loading global config", command, funcName)
-	err := cmn.InitConfig()
+	ctx := cmd.Context()
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("error loading configuration: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
 	}
-	cmn.Debug("%s: %s: global config: %#v", command, funcName, cmn.Config)
+	cmn.Debug(funcName, "project config: %#v", project)
+
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
 
-	cmn.Debug("%s: %s: config: %#v", command, funcName, config)
-	cmn.Debug("%s: %s: args: %v", command, funcName, args)
+	// Use the project default for branch unless the flag was given.
+	if !cmd.Flag("branch").Changed {
+		config.Branch = project.MvBranch
+	}
 
-	// Set the worktree current name.
-	wtCurr := args[0]
-	cmn.Debug("%s: %s: worktree name: %s", command, funcName, wtCurr)
+	cmn.Debug(funcName, "config: %#v", config)
+	cmn.Debug(funcName, "args: %v", args)
 
-	// Set the worktree current name.
-	wtNew := args[1]
-	cmn.Debug("%s: %s: new worktree name: %s", command, funcName, wtNew)
+	// Set the worktree current name, picking it if not given.
+	wtCurr := ""
+	if len(args) == 1 {
+		picked, err := project.Pick(ctx, command+"> ")
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		wtCurr = picked.Name
+	} else {
+		wtCurr = args[0]
+	}
+	cmn.Debug(funcName, "worktree name: %s", wtCurr)
 
-	// Remove the worktree.
-	output, err := git.WorktreeMove(config, wtCurr, wtNew)
+	// Move the worktree.
+	_, err = project.Move(ctx, wtCurr, args[len(args)-1], wt.MoveOptions{
+		Force:    config.Force,
+		Branch:   config.Branch,
+		Upstream: config.Upstream,
+		Out:      os.Stdout,
+	})
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
+		cmn.Trace(funcName, "error: end")
 		return err
 	}
-	fmt.Print(string(output))
 
-	cmn.Debug("%s: %s: end", command, funcName)
+	cmn.Trace(funcName, "end")
 	return nil
 }
@@ -0,0 +1,64 @@
+// Package pick implements the pick subcommand for git-wt.
+package pick
+
+import (
+	"fmt"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "pick" // Command name.
+)
+
+// New returns the cobra command for 'pick', with a configuration of its own.
+func New() *cobra.Command {
+	path := false // Whether to print the path instead of the name.
+	cmd := &cobra.Command{
+		Use:   command,
+		Short: "Pick a worktree of the project interactively.",
+		Long:  cmn.Basename + " " + command + " - Pick a worktree of the project interactively.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, path)
+		},
+	} // Cobra command definition for the 'pick' command.
+
+	cmd.PersistentFlags().BoolVarP(&path, "path", "p", false, "print the absolute path instead of the name")
+
+	return cmd
+}
+
+// run is the main function for the 'pick' command.
+func run(cmd *cobra.Command, args []string, path bool) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+
+	picked, err := project.Pick(ctx, command+"> ")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	if path {
+		fmt.Println(picked.Path)
+	} else {
+		fmt.Println(picked.Name)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
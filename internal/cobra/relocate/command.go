@@ -0,0 +1,71 @@
+// Package relocate implements the relocate subcommand for git-wt.
+package relocate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "relocate" // Command name.
+)
+
+// New returns the cobra command for 'relocate'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   command + " new_path",
+		Short: "Move the project folder.",
+		Long: cmn.Basename + " " + command + " - Move the project folder.\n\n" +
+			"Moves the project directory to new_path, which must not exist, repairs the\n" +
+			"links between every worktree and the repository, and updates paths into the\n" +
+			"project in the ." + cmn.Basename + " file. With shell integration active (see\n" +
+			"'shell-init') the shell follows the move.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE:         run,
+	} // Cobra command definition for the 'relocate' command.
+
+	return cmd
+}
+
+// run is the main function for the 'relocate' command.
+func run(cmd *cobra.Command, args []string) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+	cmn.Debug(funcName, "args: %v", args)
+
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	cwd, err := project.Relocate(cmd.Context(), args[0], wt.RelocateOptions{Out: os.Stdout})
+
+	// Follow the move in the shell, even if some links are still broken.
+	if cwd != "" {
+		ok, cdErr := cmn.RequestCd(cwd)
+		if cdErr != nil && err == nil {
+			err = cdErr
+		}
+		if cdErr == nil && !ok {
+			fmt.Printf("Your current directory moved; change to %s\n", cwd)
+		}
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package repair implements the repair subcommand for git-wt.
+package repair
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "repair" // Command name.
+)
+
+// New returns the cobra command for 'repair', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgRepair{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:   command,
+		Short: "Repair the worktree links of the project.",
+		Long: cmn.Basename + " " + command + " - Repair the worktree links of the project.\n\n" +
+			"Runs 'git worktree repair' for every worktree found in the project directory,\n" +
+			"fixing the links between worktrees and the repository after the project was\n" +
+			"moved or restored. With --prune, worktrees whose directories are gone are\n" +
+			"pruned; locked worktrees are kept.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
+	} // Cobra command definition for the 'repair' command.
+
+	cmd.PersistentFlags().BoolVar(&config.Prune, "prune", false, "prune worktrees whose directories are missing")
+
+	return cmd
+}
+
+// run is the main function for the 'repair' command.
+func run(cmd *cobra.Command, args []string, config *cmn.CfgRepair) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+	cmn.Debug(funcName, "config: %#v", config)
+
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	err = project.Repair(cmd.Context(), wt.RepairOptions{Prune: config.Prune, Out: os.Stdout})
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
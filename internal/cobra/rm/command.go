@@ -3,59 +3,94 @@ package rm
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/jason-dour/git-wt/internal/cmn"
-	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/pkg/wt"
 	"github.com/spf13/cobra"
 )
 
 var (
-	command            = "rm"         // Command name.
-	config  *cmn.CfgRm = &cmn.CfgRm{} // Configuration for the command.
-	Cmd                = &cobra.Command{
-		Use:     command,
+	command = "rm" // Command name.
+)
+
+// New returns the cobra command for 'rm', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgRm{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:     command + " [worktree_name]",
 		Short:   "Remove a worktree from the project.",
-		Long:    cmn.Basename + " " + command + " - Remove a worktree from the project.",
-		Args:    cobra.ExactArgs(1),
+		Long:    cmn.Basename + " " + command + " - Remove a worktree from the project.\n\nForce once to remove a dirty worktree, and twice to remove a locked one.\nWith --merged, every worktree whose branch is merged into the default branch\nis removed instead; locked worktrees are skipped unless forced twice.",
+		Args:    cobra.RangeArgs(0, 1),
 		Aliases: []string{"remove", "del", "delete"},
-		RunE:    run,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
 	} // Cobra command definition for the 'rm' command.
-)
 
-// init performs initialization for the 'rm' command.
-func init() {
-	Cmd.PersistentFlags().BoolVarP(&config.Force, "force", "f", false, "force removal even if worktree is dirty or locked")
+	cmd.PersistentFlags().CountVarP(&config.Force, "force", "f", "force removal even if worktree is dirty; twice if locked")
+	cmd.PersistentFlags().BoolVar(&config.Merged, "merged", false, "remove worktrees whose branch is merged into the default branch")
+
+	return cmd
 }
 
 // run is the main function for the 'rm' command.
-func run(cmd *cobra.Command, args []string) error {
-	funcName := "run"
-	cmn.Debug("%s: %s: begin", command, funcName)
+func run(cmd *cobra.Command, args []string, config *cmn.CfgRm) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
 
-	// Load global configuration.
-	cmn.Debug("%s: %s: loading global config", command, funcName)
-	err := cmn.InitConfig()
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
-		return fmt.Errorf("error loading configuration: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
 	}
-	cmn.Debug("%s: %s: global config: %#v", command, funcName, cmn.Config)
+	cmn.Debug(funcName, "project config: %#v", project)
 
-	cmn.Debug("%s: %s: config: %#v", command, funcName, config)
-	cmn.Debug("%s: %s: args: %v", command, funcName, args)
+	cmn.Debug(funcName, "config: %#v", config)
+	cmn.Debug(funcName, "args: %v", args)
 
-	// Set the worktree name.
-	wtName := args[0]
-	cmn.Debug("%s: %s: worktree name: %s", command, funcName, wtName)
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+	opts := wt.RemoveOptions{Force: config.Force, Out: os.Stdout}
+
+	// Remove merged worktrees.
+	if config.Merged {
+		if len(args) > 0 {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w: merged and worktree_name both set; use one or the other", cmn.ErrUsage)
+		}
+		_, err = project.RemoveMerged(ctx, opts)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		cmn.Trace(funcName, "end")
+		return nil
+	}
+
+	// Set the worktree name, picking it if not given.
+	wtName := ""
+	if len(args) == 0 {
+		picked, err := project.Pick(ctx, command+"> ")
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		wtName = picked.Name
+	} else {
+		wtName = args[0]
+	}
+	cmn.Debug(funcName, "worktree name: %s", wtName)
 
-	// Remove the worktree.
-	output, err := git.WorktreeRemove(config, wtName)
+	err = project.Remove(ctx, wtName, opts)
 	if err != nil {
-		cmn.Debug("%s: %s: error: end", command, funcName)
+		cmn.Trace(funcName, "error: end")
 		return err
 	}
-	fmt.Print(string(output))
 
-	cmn.Debug("%s: %s: end", command, funcName)
+	cmn.Trace(funcName, "end")
 	return nil
 }
@@ -2,34 +2,122 @@
 package root
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/cobra/cd"
 	"github.com/jason-dour/git-wt/internal/cobra/cl"
+	"github.com/jason-dour/git-wt/internal/cobra/defaultbranch"
+	"github.com/jason-dour/git-wt/internal/cobra/doctor"
+	"github.com/jason-dour/git-wt/internal/cobra/exec"
+	"github.com/jason-dour/git-wt/internal/cobra/lock"
 	"github.com/jason-dour/git-wt/internal/cobra/ls"
 	"github.com/jason-dour/git-wt/internal/cobra/mk"
 	"github.com/jason-dour/git-wt/internal/cobra/mv"
+	"github.com/jason-dour/git-wt/internal/cobra/pick"
+	"github.com/jason-dour/git-wt/internal/cobra/relocate"
+	"github.com/jason-dour/git-wt/internal/cobra/repair"
 	"github.com/jason-dour/git-wt/internal/cobra/rm"
+	"github.com/jason-dour/git-wt/internal/cobra/shellinit"
+	"github.com/jason-dour/git-wt/internal/cobra/sync"
+	"github.com/jason-dour/git-wt/internal/cobra/ui"
+	"github.com/jason-dour/git-wt/internal/cobra/undo"
+	"github.com/jason-dour/git-wt/internal/cobra/unlock"
 	"github.com/jason-dour/git-wt/internal/cobra/xx"
 	"github.com/spf13/cobra"
 )
 
-var (
-	Cmd = &cobra.Command{
+// New returns the root command with a fresh instance of every subcommand.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
 		Use:     cmn.Basename,
 		Long:    cmn.Basename + " - A git helper for managing worktrees as part of a workflow.",
 		Args:    cobra.NoArgs,
 		Version: cmn.Version,
 	} // Cobra root command definition.
-)
 
-func init() {
 	// Command flags.
-	Cmd.PersistentFlags().BoolVarP(&cmn.Config.DebugFlag, "debug", "d", false, "enable debug mode")
+	var (
+		debug     bool               // Whether to log at the debug level.
+		logLevel  string             // Level to log at.
+		logFormat string             // Format of log records.
+		timeout   time.Duration      // Time limit of the command; none if zero.
+		cancel    context.CancelFunc // Releases the timeout of the command.
+	)
+	cmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "log at the debug level; same as --log-level debug")
+	cmd.PersistentFlags().StringVar(&logLevel, "log-level", "warn", "log level: trace, debug, info, warn or error")
+	cmd.PersistentFlags().StringVar(&logFormat, "log-format", cmn.LogFormatText, "log format: text or json")
+	cmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "time limit of the command, e.g. 30s or 5m; none if 0")
+	cmd.PersistentFlags().Duration(cmn.LockWaitFlag, 0, "how long to wait for another "+cmn.Basename+" changing the project; fail at once if 0")
+
+	// Set up logging and the time limit before any command runs.
+	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if debug && !cmd.Flags().Changed("log-level") {
+			logLevel = "debug"
+		}
+		err := cmn.SetupLog(logLevel, logFormat)
+		if err != nil {
+			return err
+		}
+
+		if timeout < 0 {
+			return fmt.Errorf("%w: invalid timeout: %s", cmn.ErrUsage, timeout)
+		}
+		if timeout > 0 {
+			ctx, cancelTimeout := context.WithTimeoutCause(cmd.Context(), timeout, fmt.Errorf("%w after %s", cmn.ErrTimeout, timeout))
+			cmd.SetContext(ctx)
+			cancel = cancelTimeout
+		}
+		return nil
+	}
+	cmd.PersistentPostRun = func(cmd *cobra.Command, args []string) {
+		if cancel != nil {
+			cancel()
+		}
+	}
 
 	// Sub-Commands
-	Cmd.AddCommand(cl.Cmd)
-	Cmd.AddCommand(ls.Cmd)
-	Cmd.AddCommand(mk.Cmd)
-	Cmd.AddCommand(mv.Cmd)
-	Cmd.AddCommand(rm.Cmd)
-	Cmd.AddCommand(xx.Cmd)
+	cmd.AddCommand(cd.New())
+	cmd.AddCommand(cl.New())
+	cmd.AddCommand(defaultbranch.New())
+	cmd.AddCommand(doctor.New())
+	cmd.AddCommand(exec.New())
+	cmd.AddCommand(lock.New())
+	cmd.AddCommand(ls.New())
+	cmd.AddCommand(mk.New())
+	cmd.AddCommand(mv.New())
+	cmd.AddCommand(pick.New())
+	cmd.AddCommand(relocate.New())
+	cmd.AddCommand(repair.New())
+	cmd.AddCommand(rm.New())
+	cmd.AddCommand(shellinit.New())
+	cmd.AddCommand(sync.New())
+	cmd.AddCommand(ui.New())
+	cmd.AddCommand(undo.New())
+	cmd.AddCommand(unlock.New())
+	cmd.AddCommand(xx.New())
+
+	// Invalid flags and arguments are usage errors, with an exit code of
+	// their own.
+	cmd.SetFlagErrorFunc(usageError)
+	for _, v := range append([]*cobra.Command{cmd}, cmd.Commands()...) {
+		if v.Args != nil {
+			args := v.Args
+			v.Args = func(c *cobra.Command, a []string) error {
+				return usageError(c, args(c, a))
+			}
+		}
+	}
+
+	return cmd
+}
+
+// usageError wraps an error validating flags or arguments as cmn.ErrUsage.
+func usageError(cmd *cobra.Command, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", cmn.ErrUsage, err)
 }
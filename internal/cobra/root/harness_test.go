@@ -0,0 +1,263 @@
+package root_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jason-dour/git-wt/internal/cobra/root"
+)
+
+type (
+	remoteOptions struct {
+		Head     string         // Default branch of the remote; "main" if empty.
+		Branches []string       // Further branches, each with a commit of its own.
+		Pulls    map[int]string // Fake refs/pull/<n>/head refs, pointing at a branch's tip.
+	} // Options for newRemote.
+
+	remote struct {
+		t    *testing.T
+		Dir  string // Path of the bare repository.
+		URL  string // file:// URL of the bare repository.
+		Head string // Default branch of the remote.
+	} // Throwaway bare repository serving as the remote of a project.
+)
+
+// hermetic isolates git from the user's and system's configuration and gives
+// it an identity, so the tests behave the same everywhere and offline.
+func hermetic(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := filepath.Join(dir, "home")
+	if err := os.Mkdir(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", filepath.Join(home, ".gitconfig"))
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_TERMINAL_PROMPT", "0")
+	t.Setenv("GIT_WT_CD_FILE", "")
+
+	return dir
+}
+
+// git runs git in dir and returns its trimmed output, failing the test on error.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s in %s: %s: %s", strings.Join(args, " "), dir, err.Error(), output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// newRemote creates a bare repository in dir with a commit on the default
+// branch, the given branches and fake pull request refs.
+func newRemote(t *testing.T, dir string, opts remoteOptions) *remote {
+	t.Helper()
+
+	if opts.Head == "" {
+		opts.Head = "main"
+	}
+	r := &remote{t: t, Dir: filepath.Join(dir, "origin.git"), Head: opts.Head}
+	r.URL = "file://" + filepath.ToSlash(r.Dir)
+
+	git(t, dir, "init", "--quiet", "--bare", "--initial-branch", opts.Head, r.Dir)
+
+	// Seed the branches from a scratch clone.
+	seed := filepath.Join(dir, "seed")
+	git(t, dir, "init", "--quiet", "--initial-branch", opts.Head, seed)
+	git(t, seed, "remote", "add", "origin", r.URL)
+	r.commit(seed, "README", "seed\n")
+	for _, v := range opts.Branches {
+		git(t, seed, "checkout", "--quiet", "-b", v, opts.Head)
+		r.commit(seed, strings.ReplaceAll(v, "/", "-")+".txt", v+"\n")
+	}
+	git(t, seed, "push", "--quiet", "origin", "refs/heads/*:refs/heads/*")
+
+	for n, branch := range opts.Pulls {
+		git(t, seed, "push", "--quiet", "origin", fmt.Sprintf("refs/heads/%s:refs/pull/%d/head", branch, n))
+	}
+
+	git(t, r.Dir, "symbolic-ref", "HEAD", "refs/heads/"+opts.Head)
+	if err := os.RemoveAll(seed); err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+// commit writes a file in the worktree at dir and commits it.
+func (r *remote) commit(dir string, name string, contents string) {
+	r.t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		r.t.Fatal(err)
+	}
+	git(r.t, dir, "add", name)
+	git(r.t, dir, "commit", "--quiet", "-m", "add "+name)
+}
+
+// Ref returns the commit id a ref of the remote points at.
+func (r *remote) Ref(ref string) string {
+	r.t.Helper()
+	return git(r.t, r.Dir, "rev-parse", ref)
+}
+
+// run runs git-wt with args in dir through the cobra commands, returning what
+// it printed to stdout and the error it returned.
+func run(t *testing.T, dir string, args ...string) (string, error) {
+	t.Helper()
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(cwd)
+
+	// Commands print to os.Stdout; capture it for the duration of the run.
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	captured := make(chan string)
+	go func() {
+		output, _ := io.ReadAll(reader)
+		captured <- string(output)
+	}()
+
+	cmd := root.New()
+	cmd.SetArgs(args)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(&bytes.Buffer{})
+	err = cmd.Execute()
+
+	writer.Close()
+	os.Stdout = stdout
+	return <-captured, err
+}
+
+// mustRun runs git-wt like run, failing the test if the command fails.
+func mustRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	output, err := run(t, dir, args...)
+	if err != nil {
+		t.Fatalf("git-wt %s: %s\n%s", strings.Join(args, " "), err.Error(), output)
+	}
+	return output
+}
+
+// clone creates a project from the remote in dir with 'cl', returning the
+// project directory.
+func clone(t *testing.T, dir string, r *remote) string {
+	t.Helper()
+
+	mustRun(t, dir, "cl", r.URL)
+	return filepath.Join(dir, strings.TrimSuffix(filepath.Base(r.Dir), ".git"))
+}
+
+// worktrees returns the paths of the worktrees git knows of, sorted.
+func worktrees(t *testing.T, dir string) []string {
+	t.Helper()
+
+	paths := []string{}
+	for _, line := range strings.Split(git(t, dir, "worktree", "list", "--porcelain"), "\n") {
+		if path, found := strings.CutPrefix(line, "worktree "); found {
+			paths = append(paths, filepath.Clean(path))
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// locks returns the reasons of the locked worktrees git knows of, by path.
+func locks(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	reasons := map[string]string{}
+	path := ""
+	for _, line := range strings.Split(git(t, dir, "worktree", "list", "--porcelain"), "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "worktree":
+			path = filepath.Clean(value)
+		case "locked":
+			reasons[path] = value
+		}
+	}
+	return reasons
+}
+
+// branches returns the local branches of the repository, sorted.
+func branches(t *testing.T, dir string) []string {
+	t.Helper()
+
+	list := strings.Fields(git(t, dir, "branch", "--format=%(refname:short)"))
+	sort.Strings(list)
+	return list
+}
+
+// assertExists fails the test unless path exists.
+func assertExists(t *testing.T, path string) {
+	t.Helper()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected %s to exist: %s", path, err.Error())
+	}
+}
+
+// assertMissing fails the test if path exists.
+func assertMissing(t *testing.T, path string) {
+	t.Helper()
+
+	if _, err := os.Lstat(path); err == nil {
+		t.Errorf("expected %s not to exist", path)
+	}
+}
+
+// assertEqual fails the test unless got equals want.
+func assertEqual[T comparable](t *testing.T, what string, got T, want T) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+// assertList fails the test unless got holds the same strings as want.
+func assertList(t *testing.T, what string, got []string, want []string) {
+	t.Helper()
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("%s: got %q, want %q", what, got, want)
+	}
+}
@@ -0,0 +1,1117 @@
+package root_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+)
+
+func TestClone(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{Head: "trunk", Branches: []string{"dev"}})
+
+	project := clone(t, dir, r)
+
+	assertExists(t, filepath.Join(project, "trunk", "README"))
+	config, err := os.ReadFile(filepath.Join(project, ".git-wt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "config", string(config), "default: trunk\n")
+	assertEqual(t, "branch", git(t, filepath.Join(project, "trunk"), "rev-parse", "--abbrev-ref", "HEAD"), "trunk")
+	assertEqual(t, "head", git(t, filepath.Join(project, "trunk"), "rev-parse", "HEAD"), r.Ref("trunk"))
+}
+
+func TestCloneExisting(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	clone(t, dir, r)
+
+	_, err := run(t, dir, "cl", r.URL)
+	if err == nil {
+		t.Fatal("expected cloning over an existing project to fail")
+	}
+}
+
+func TestCloneFails(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	objects := filepath.Join(r.Dir, "objects")
+	if err := os.Rename(objects, objects+".hidden"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(objects, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := run(t, dir, "cl", r.URL)
+
+	if err == nil {
+		t.Fatal("expected cloning a remote without objects to fail")
+	}
+	assertMissing(t, filepath.Join(dir, "origin"))
+
+	// A retry finds nothing left behind.
+	if err := os.RemoveAll(objects); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(objects+".hidden", objects); err != nil {
+		t.Fatal(err)
+	}
+	project := clone(t, dir, r)
+	assertExists(t, filepath.Join(project, "main", "README"))
+}
+
+func TestMk(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{Branches: []string{"dev"}})
+	project := clone(t, dir, r)
+
+	// A new branch from the default branch.
+	mustRun(t, project, "mk", "-b", "feature", "feature", "main")
+	assertEqual(t, "branch", git(t, filepath.Join(project, "feature"), "rev-parse", "--abbrev-ref", "HEAD"), "feature")
+
+	// A new branch from a remote branch, tracking it.
+	mustRun(t, project, "mk", "-b", "dev", "--track", "dev", "origin/dev")
+	assertExists(t, filepath.Join(project, "dev", "dev.txt"))
+	assertEqual(t, "upstream", git(t, filepath.Join(project, "dev"), "rev-parse", "--abbrev-ref", "dev@{upstream}"), "origin/dev")
+
+	// A new branch from the remote default branch.
+	mustRun(t, project, "mk", "--from-default", "-b", "fresh", "fresh")
+	assertEqual(t, "head", git(t, filepath.Join(project, "fresh"), "rev-parse", "HEAD"), r.Ref("main"))
+
+	// From a worktree of the project rather than the project directory.
+	mustRun(t, filepath.Join(project, "feature"), "mk", "-b", "nested/name", "nested/name", "main")
+	assertExists(t, filepath.Join(project, "nested", "name", "README"))
+
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{
+		filepath.Join(project, "dev"),
+		filepath.Join(project, "feature"),
+		filepath.Join(project, "fresh"),
+		filepath.Join(project, "main"),
+		filepath.Join(project, "nested", "name"),
+	})
+}
+
+func TestMkCopy(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	config, err := os.OpenFile(filepath.Join(project, ".git-wt"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(config, "copy: .env\n")
+	config.Close()
+	if err := os.WriteFile(filepath.Join(project, "main", ".env"), []byte("secret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Local files are carried from the default worktree, unless told not to.
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	assertExists(t, filepath.Join(project, "one", ".env"))
+	mustRun(t, project, "mk", "--no-copy", "-b", "two", "two", "main")
+	assertExists(t, filepath.Join(project, "two", "README"))
+	assertMissing(t, filepath.Join(project, "two", ".env"))
+}
+
+func TestMkFetch(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	scratch := filepath.Join(dir, "scratch")
+	git(t, dir, "clone", "--quiet", r.URL, scratch)
+	push := func(contents string) string {
+		t.Helper()
+		r.commit(scratch, "README", contents)
+		git(t, scratch, "push", "--quiet", "origin", "main")
+		return r.Ref("main")
+	}
+
+	// Fetching first builds on the commit pushed since the clone.
+	pushed := push("one\n")
+	mustRun(t, project, "mk", "--fetch", "-b", "one", "one", "origin/main")
+	assertEqual(t, "head", git(t, filepath.Join(project, "one"), "rev-parse", "HEAD"), pushed)
+
+	// The flag overrides the project default.
+	config, err := os.OpenFile(filepath.Join(project, ".git-wt"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(config, "fetch: true\n")
+	config.Close()
+	fetched := pushed
+	pushed = push("two\n")
+	mustRun(t, project, "mk", "--fetch=false", "-b", "two", "two", "origin/main")
+	assertEqual(t, "head", git(t, filepath.Join(project, "two"), "rev-parse", "HEAD"), fetched)
+	mustRun(t, project, "mk", "-b", "three", "three", "origin/main")
+	assertEqual(t, "head", git(t, filepath.Join(project, "three"), "rev-parse", "HEAD"), pushed)
+}
+
+func TestMkInvalid(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+
+	for _, args := range [][]string{
+		{"mk", "feature"},
+		{"mk", "-b", "a", "-B", "b", "feature", "main"},
+		{"mk", "--track", "feature", "main"},
+		{"mk", "--from-default", "feature", "main"},
+		{"mk", "../outside", "main"},
+		{"mk", ".hidden", "main"},
+	} {
+		if _, err := run(t, project, args...); err == nil {
+			t.Errorf("git-wt %s: expected an error", strings.Join(args, " "))
+		}
+	}
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main")})
+}
+
+func TestMkPullRequest(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{
+		Branches: []string{"contrib"},
+		Pulls:    map[int]string{7: "contrib"},
+	})
+	project := clone(t, dir, r)
+
+	mustRun(t, project, "mk", "review", "pr/7")
+
+	path := filepath.Join(project, "review")
+	assertEqual(t, "head", git(t, path, "rev-parse", "HEAD"), r.Ref("refs/pull/7/head"))
+	assertExists(t, filepath.Join(path, "contrib.txt"))
+}
+
+func TestLs(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "--lock", "--reason", "on hold", "-b", "feature", "feature", "main")
+
+	output := mustRun(t, project, "ls")
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", output)
+	}
+	short := r.Ref("main")[:7]
+	assertEqual(t, "main", strings.Fields(lines[0])[0], filepath.Join(project, "main"))
+	assertEqual(t, "main", strings.Join(strings.Fields(lines[0])[1:], " "), short+" [main]")
+	assertEqual(t, "feature", strings.Fields(lines[1])[0], filepath.Join(project, "feature"))
+	assertEqual(t, "feature", strings.Join(strings.Fields(lines[1])[1:], " "), short+" [feature]")
+	assertEqual(t, "lock", strings.TrimSpace(lines[2]), "locked: on hold")
+}
+
+func TestLsOutsideProject(t *testing.T) {
+	dir := hermetic(t)
+
+	_, err := run(t, dir, "ls")
+	if err == nil || !strings.Contains(err.Error(), "no .git-wt file") {
+		t.Fatalf("expected a missing project error, got %v", err)
+	}
+}
+
+func TestCd(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	cdFile := filepath.Join(dir, "cd")
+	readCd := func() string {
+		t.Helper()
+		path, err := os.ReadFile(cdFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(cdFile)
+		return string(path)
+	}
+
+	// Without shell integration, the path is printed.
+	output := mustRun(t, project, "cd", "one")
+	assertEqual(t, "output", output, filepath.Join(project, "one")+"\n")
+	assertMissing(t, cdFile)
+
+	// With it, the path goes to the cd file.
+	t.Setenv(cmn.CdFileEnv, cdFile)
+	output = mustRun(t, filepath.Join(project, "main"), "cd", "one")
+	assertEqual(t, "output", output, "")
+	assertEqual(t, "cd file", readCd(), filepath.Join(project, "one"))
+
+	// Back and forth to the previous worktree.
+	mustRun(t, filepath.Join(project, "one"), "cd", "-")
+	assertEqual(t, "cd file", readCd(), filepath.Join(project, "main"))
+	mustRun(t, filepath.Join(project, "main"), "cd", "-")
+	assertEqual(t, "cd file", readCd(), filepath.Join(project, "one"))
+
+	// As path, only printed.
+	output = mustRun(t, project, "path", "one")
+	assertEqual(t, "output", output, filepath.Join(project, "one")+"\n")
+	assertMissing(t, cdFile)
+
+	// To a new worktree.
+	mustRun(t, filepath.Join(project, "main"), "mk", "--cd", "-b", "two", "two", "main")
+	assertEqual(t, "cd file", readCd(), filepath.Join(project, "two"))
+	mustRun(t, filepath.Join(project, "two"), "cd", "-")
+	assertEqual(t, "cd file", readCd(), filepath.Join(project, "main"))
+}
+
+func TestShellInit(t *testing.T) {
+	dir := hermetic(t)
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not found in PATH")
+	}
+	script := mustRun(t, dir, "shell-init", "bash")
+	if !strings.Contains(script, cmn.CdFileEnv) {
+		t.Fatalf("shell integration does not set %s:\n%s", cmn.CdFileEnv, script)
+	}
+
+	// A stand-in for the program writes its argument to the cd file, as cd
+	// would; the shell changes to it and cleans up.
+	bin := filepath.Join(dir, "bin")
+	target := filepath.Join(dir, "target")
+	for _, v := range []string{bin, target} {
+		if err := os.Mkdir(v, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stub := "#!/bin/sh\nprintf %s \"$2\" > \"$" + cmn.CdFileEnv + "\"\n"
+	if err := os.WriteFile(filepath.Join(bin, cmn.Basename), []byte(stub), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(bash, "-c", script+"\ngit wt cd \"$1\" && pwd", "bash", target)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"), "TMPDIR="+dir)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("shell integration failed: %v: %s", err, output)
+	}
+
+	assertEqual(t, "directory", strings.TrimSpace(string(output)), target)
+	leftovers, _ := filepath.Glob(filepath.Join(dir, cmn.Basename+".*"))
+	assertList(t, "cd files left behind", leftovers, nil)
+}
+
+func TestMv(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "feature", "feature", "main")
+
+	mustRun(t, project, "mv", "feature", "topic/renamed")
+
+	assertMissing(t, filepath.Join(project, "feature"))
+	assertExists(t, filepath.Join(project, "topic", "renamed", "README"))
+	assertEqual(t, "branch", git(t, filepath.Join(project, "topic", "renamed"), "rev-parse", "--abbrev-ref", "HEAD"), "feature")
+
+	// Renaming the branch along with the worktree.
+	mustRun(t, project, "mv", "--branch", "topic/renamed", "final")
+
+	assertMissing(t, filepath.Join(project, "topic"))
+	assertEqual(t, "branch", git(t, filepath.Join(project, "final"), "rev-parse", "--abbrev-ref", "HEAD"), "final")
+	assertList(t, "branches", branches(t, project+"/main"), []string{"final", "main"})
+}
+
+func TestMvDefaultBranch(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+
+	if _, err := run(t, project, "mv", "--branch", "main", "other"); err == nil {
+		t.Fatal("expected renaming the default branch to fail")
+	}
+	assertExists(t, filepath.Join(project, "main"))
+}
+
+func TestRm(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "feature", "feature", "main")
+	mustRun(t, project, "mk", "-b", "nested/name", "nested/name", "main")
+
+	mustRun(t, project, "rm", "feature")
+	mustRun(t, project, "rm", "nested/name")
+
+	assertMissing(t, filepath.Join(project, "feature"))
+	assertMissing(t, filepath.Join(project, "nested"))
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main")})
+	assertList(t, "branches", branches(t, project+"/main"), []string{"feature", "main", "nested/name"})
+}
+
+func TestRmDirtyAndLocked(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "dirty", "dirty", "main")
+	mustRun(t, project, "mk", "--lock", "-b", "locked", "locked", "main")
+	if err := os.WriteFile(filepath.Join(project, "dirty", "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := run(t, project, "rm", "dirty"); err == nil {
+		t.Error("expected removing a dirty worktree to fail")
+	}
+	mustRun(t, project, "rm", "-f", "dirty")
+	assertMissing(t, filepath.Join(project, "dirty"))
+
+	if _, err := run(t, project, "rm", "-f", "locked"); err == nil {
+		t.Error("expected removing a locked worktree forced once to fail")
+	}
+	mustRun(t, project, "rm", "-ff", "locked")
+	assertMissing(t, filepath.Join(project, "locked"))
+}
+
+func TestRmMerged(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "merged", "merged", "main")
+	mustRun(t, project, "mk", "-b", "unmerged", "unmerged", "main")
+	r.commit(filepath.Join(project, "unmerged"), "work.txt", "work\n")
+
+	mustRun(t, project, "rm", "--merged")
+
+	assertMissing(t, filepath.Join(project, "merged"))
+	assertExists(t, filepath.Join(project, "unmerged", "work.txt"))
+}
+
+func TestXx(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	mustRun(t, project, "mk", "-b", "two", "two", "main")
+	git(t, filepath.Join(project, "main"), "branch", "loose")
+
+	// Worktrees only.
+	mustRun(t, project, "xx", "-w")
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main")})
+	assertList(t, "branches", branches(t, project+"/main"), []string{"loose", "main", "one", "two"})
+
+	// Branches only.
+	mustRun(t, project, "xx", "-b")
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main"})
+}
+
+func TestXxMost(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	mustRun(t, project, "mk", "--lock", "-b", "kept", "kept", "main")
+
+	mustRun(t, project, "xx", "-m")
+
+	assertMissing(t, filepath.Join(project, "one"))
+	assertExists(t, filepath.Join(project, "kept"))
+	assertList(t, "branches", branches(t, project+"/main"), []string{"kept", "main"})
+}
+
+func TestXxAll(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	if err := os.WriteFile(filepath.Join(project, "main", "scratch.txt"), []byte("scratch\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mustRun(t, project, "xx", "-a")
+
+	entries, err := os.ReadDir(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, v := range entries {
+		names = append(names, v.Name())
+	}
+	assertList(t, "project", names, []string{".git-wt", ".git-wt.d", "main"})
+	assertMissing(t, filepath.Join(project, "main", "scratch.txt"))
+	assertEqual(t, "head", git(t, filepath.Join(project, "main"), "rev-parse", "HEAD"), r.Ref("main"))
+}
+
+func TestXxAllCloneFails(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	git(t, filepath.Join(project, "main"), "remote", "set-url", "origin", filepath.Join(dir, "missing.git"))
+
+	_, err := run(t, project, "xx", "-a")
+
+	assertEqual(t, "exit code", cmn.ExitCode(err), cmn.ExitRemote)
+	if err == nil || !strings.Contains(err.Error(), "unchanged") {
+		t.Errorf("error does not say the project is unchanged: %v", err)
+	}
+	assertExists(t, filepath.Join(project, "one"))
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main"), filepath.Join(project, "one")})
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range entries {
+		if strings.Contains(v.Name(), ".xx-") {
+			t.Errorf("temporary directory left behind: %s", v.Name())
+		}
+	}
+}
+
+func TestXxMostRollback(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	r.commit(filepath.Join(project, "one"), "work.txt", "work\n")
+	head := git(t, filepath.Join(project, "one"), "rev-parse", "HEAD")
+	git(t, filepath.Join(project, "main"), "branch", "two")
+	// A stale ref lock makes deleting a branch, or checking it out, fail.
+	refs := filepath.Join(project, "main", ".git", "refs", "heads")
+	refLock := func(branch string) string {
+		t.Helper()
+		path := filepath.Join(refs, branch+".lock")
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Rolled back in full.
+	lock := refLock("two")
+	_, err := run(t, project, "xx", "-m")
+	if err == nil || !strings.Contains(err.Error(), "rolled back all 2 changes") {
+		t.Errorf("error does not report the roll back: %v", err)
+	}
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main", "one", "two"})
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main"), filepath.Join(project, "one")})
+	assertEqual(t, "head", git(t, filepath.Join(project, "one"), "rev-parse", "HEAD"), head)
+	os.Remove(lock)
+
+	// Rolled back in part, leaving the rest to undo.
+	mustRun(t, project, "mk", "-b", "three", "three", "main")
+	lock = refLock("three")
+	_, err = run(t, project, "xx", "-m")
+	if err == nil || !strings.Contains(err.Error(), "run undo to restore the rest") {
+		t.Errorf("error does not report the partial roll back: %v", err)
+	}
+	os.Remove(lock)
+	mustRun(t, project, "undo")
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main"), filepath.Join(project, "one"), filepath.Join(project, "three")})
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main", "one", "three", "two"})
+}
+
+func TestXxChecks(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+
+	for _, args := range [][]string{
+		{"xx"},
+		{"xx", "-m", "-a"},
+		{"xx", "-a", "-b"},
+	} {
+		if _, err := run(t, project, args...); err == nil {
+			t.Errorf("git-wt %s: expected an error", strings.Join(args, " "))
+		}
+	}
+
+	// Only from the project directory.
+	if _, err := run(t, filepath.Join(project, "main"), "xx", "-b"); err == nil {
+		t.Error("expected xx outside the project directory to fail")
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "feature/a", "feature/a", "main")
+	mustRun(t, project, "mk", "-b", "feature/b", "feature/b", "main")
+	mustRun(t, project, "mk", "-b", "other", "other", "main")
+	if err := os.WriteFile(filepath.Join(project, "feature", "b", "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// ranIn returns the worktrees whose output prefix appears in output.
+	ranIn := func(output string) []string {
+		names := []string{}
+		for _, v := range []string{"feature/a", "feature/b", "main", "other"} {
+			if strings.Contains(output, "["+v+"] ") {
+				names = append(names, v)
+			}
+		}
+		return names
+	}
+
+	tests := []struct {
+		name    string
+		filters []string // Filters given with --filter.
+		want    []string // Worktrees the command runs in.
+	}{
+		{name: "all", want: []string{"feature/a", "feature/b", "main", "other"}},
+		{name: "name glob", filters: []string{"feature/*"}, want: []string{"feature/a", "feature/b"}},
+		{name: "name key", filters: []string{"name=other"}, want: []string{"other"}},
+		{name: "branch", filters: []string{"branch=feature/*"}, want: []string{"feature/a", "feature/b"}},
+		{name: "dirty", filters: []string{"status=dirty"}, want: []string{"feature/b"}},
+		{name: "all must match", filters: []string{"feature/*", "status=clean"}, want: []string{"feature/a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []string{"exec"}
+			for _, v := range tt.filters {
+				args = append(args, "--filter", v)
+			}
+			args = append(args, "--", "echo $GIT_WT_WORKTREE")
+
+			output := mustRun(t, project, args...)
+
+			assertList(t, "worktrees", ranIn(output), tt.want)
+		})
+	}
+
+	// Invalid filters are usage errors, and no worktree matching is an error.
+	for _, v := range []string{"owner=me", "status=maybe", "name=[a"} {
+		_, err := run(t, project, "exec", "--filter", v, "--", "true")
+		assertEqual(t, "exit code for "+v, cmn.ExitCode(err), cmn.ExitUsage)
+	}
+	if _, err := run(t, project, "exec", "--filter", "missing", "--", "true"); err == nil {
+		t.Error("expected exec without matching worktrees to fail")
+	}
+
+	// The summary gives each worktree's exit code.
+	output, err := run(t, project, "exec", "-p", "2", "--", "test -f new.txt || exit 3")
+	if err == nil || !strings.Contains(err.Error(), "failed in 3 of 4 worktrees") {
+		t.Errorf("error does not count the failures: %v", err)
+	}
+	codes := map[string]string{}
+	for _, v := range strings.Split(output, "\n") {
+		if fields := strings.Fields(v); len(fields) == 3 && !strings.HasPrefix(v, "[") {
+			codes[fields[0]] = fields[1]
+		}
+	}
+	for name, want := range map[string]string{"feature/a": "3", "feature/b": "0", "main": "3", "other": "3"} {
+		assertEqual(t, "exit code of "+name, codes[name], want)
+	}
+}
+
+func TestSync(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	for _, v := range []string{"clean", "conflict", "dirty"} {
+		mustRun(t, project, "mk", "-b", v, v, "main")
+	}
+	r.commit(filepath.Join(project, "clean"), "clean.txt", "clean\n")
+	r.commit(filepath.Join(project, "conflict"), "README", "conflict\n")
+	conflictHead := git(t, filepath.Join(project, "conflict"), "rev-parse", "HEAD")
+	if err := os.WriteFile(filepath.Join(project, "dirty", "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Change the README on the remote.
+	scratch := filepath.Join(dir, "scratch")
+	git(t, dir, "clone", "--quiet", r.URL, scratch)
+	r.commit(scratch, "README", "remote\n")
+	git(t, scratch, "push", "--quiet", "origin", "main")
+	// inProgress reports whether git has a rebase or merge stopped in the worktree.
+	inProgress := func(name string) bool {
+		t.Helper()
+		for _, v := range []string{"rebase-merge", "rebase-apply", "MERGE_HEAD"} {
+			path := git(t, filepath.Join(project, name), "rev-parse", "--path-format=absolute", "--git-path", v)
+			if _, err := os.Stat(path); err == nil {
+				return true
+			}
+		}
+		return false
+	}
+	// results returns the result of each worktree in the summary.
+	results := func(output string) map[string]string {
+		results := map[string]string{}
+		for _, v := range strings.Split(output, "\n") {
+			if name, result, found := strings.Cut(v, "  "); found {
+				results[name] = strings.TrimSpace(result)
+			}
+		}
+		return results
+	}
+
+	// Rebasing aborts the conflict and reports it.
+	output, err := run(t, project, "sync", "--rebase")
+
+	if err == nil || !strings.Contains(err.Error(), "could not update 1 of 4 worktrees") {
+		t.Errorf("error does not report the conflict: %v", err)
+	}
+	got := results(output)
+	assertEqual(t, "main", got["main"], "fast-forwarded")
+	assertEqual(t, "clean", got["clean"], "rebased")
+	assertEqual(t, "conflict", got["conflict"], "conflict; rebase aborted")
+	assertEqual(t, "dirty", got["dirty"], "skipped: uncommitted changes")
+	assertEqual(t, "main head", git(t, filepath.Join(project, "main"), "rev-parse", "HEAD"), r.Ref("main"))
+	assertEqual(t, "clean base", git(t, filepath.Join(project, "clean"), "rev-parse", "HEAD~1"), r.Ref("main"))
+	assertEqual(t, "conflict head", git(t, filepath.Join(project, "conflict"), "rev-parse", "HEAD"), conflictHead)
+	assertEqual(t, "conflict in progress", inProgress("conflict"), false)
+	assertEqual(t, "conflict status", git(t, filepath.Join(project, "conflict"), "status", "--porcelain"), "")
+
+	// So does merging.
+	output, err = run(t, project, "sync", "--merge")
+
+	if err == nil {
+		t.Error("expected the conflicting merge to fail")
+	}
+	got = results(output)
+	assertEqual(t, "main", got["main"], "up to date")
+	assertEqual(t, "clean", got["clean"], "up to date")
+	assertEqual(t, "conflict", got["conflict"], "conflict; merge aborted")
+	assertEqual(t, "conflict head", git(t, filepath.Join(project, "conflict"), "rev-parse", "HEAD"), conflictHead)
+	assertEqual(t, "conflict in progress", inProgress("conflict"), false)
+	assertEqual(t, "conflict status", git(t, filepath.Join(project, "conflict"), "status", "--porcelain"), "")
+}
+
+func TestLockUnlock(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	one := filepath.Join(project, "one")
+
+	output := mustRun(t, project, "lock", "--reason", "on hold", "one")
+
+	assertEqual(t, "output", strings.TrimSpace(output), "Locked worktree: one")
+	reason, locked := locks(t, one)[one]
+	assertEqual(t, "locked", locked, true)
+	assertEqual(t, "reason", reason, "on hold")
+	_, err := run(t, project, "lock", "one")
+	assertEqual(t, "lock again exit code", cmn.ExitCode(err), cmn.ExitLocked)
+
+	// Locked worktrees are neither removed nor moved, even when forced once.
+	_, err = run(t, project, "rm", "-f", "one")
+	assertEqual(t, "rm exit code", cmn.ExitCode(err), cmn.ExitLocked)
+	_, err = run(t, project, "mv", "-f", "one", "two")
+	assertEqual(t, "mv exit code", cmn.ExitCode(err), cmn.ExitLocked)
+	assertExists(t, one)
+
+	output = mustRun(t, one, "unlock", "one")
+
+	assertEqual(t, "output", strings.TrimSpace(output), "Unlocked worktree: one")
+	_, locked = locks(t, one)[one]
+	assertEqual(t, "locked", locked, false)
+	if _, err := run(t, project, "unlock", "one"); err == nil {
+		t.Error("expected unlocking an unlocked worktree to fail")
+	}
+	mustRun(t, project, "mv", "one", "two")
+	assertExists(t, filepath.Join(project, "two"))
+}
+
+func TestDoctorRepair(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	mustRun(t, project, "mk", "-b", "two", "two", "main")
+	// findings returns the doctor's findings at a level.
+	findings := func(output string, level string) []string {
+		found := []string{}
+		for _, v := range strings.Split(output, "\n") {
+			if fields := strings.Fields(v); len(fields) > 2 && fields[0] == level {
+				found = append(found, strings.Join(fields[1:], " "))
+			}
+		}
+		return found
+	}
+
+	output := mustRun(t, project, "doctor")
+	assertList(t, "failures", findings(output, "fail"), []string{})
+	assertList(t, "warnings", findings(output, "warn"), []string{})
+
+	// Moved behind git's back, removed, and a stray directory.
+	if err := os.Rename(filepath.Join(project, "one"), filepath.Join(project, "moved")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(filepath.Join(project, "two")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(project, "stray"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "stray", "file.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := run(t, project, "doctor")
+
+	if err == nil || !strings.Contains(err.Error(), "found 3 problems") {
+		t.Errorf("error does not count the problems: %v", err)
+	}
+	failures := findings(output, "fail")
+	for i, want := range []string{"worktrees worktree missing: " + filepath.Join(project, "one"), "worktrees worktree missing: " + filepath.Join(project, "two"), "links moved: "} {
+		if i >= len(failures) || !strings.HasPrefix(failures[i], want) {
+			t.Errorf("failure %d: got %q, want %q...", i, failures, want)
+		}
+	}
+	assertList(t, "warnings", findings(output, "warn"), []string{"strays directory holds no worktree: stray"})
+
+	// Repair relinks the moved worktree and reports the removed one.
+	output = mustRun(t, project, "repair")
+
+	if !strings.Contains(output, "Repaired worktree: moved") {
+		t.Errorf("repair does not report the moved worktree: %q", output)
+	}
+	if !strings.Contains(output, "Missing worktree: "+filepath.Join(project, "two")) {
+		t.Errorf("repair does not report the removed worktree: %q", output)
+	}
+	assertList(t, "worktrees", worktrees(t, filepath.Join(project, "main")), []string{filepath.Join(project, "main"), filepath.Join(project, "moved"), filepath.Join(project, "two")})
+	assertEqual(t, "moved branch", git(t, filepath.Join(project, "moved"), "rev-parse", "--abbrev-ref", "HEAD"), "one")
+
+	mustRun(t, project, "repair", "--prune")
+	assertList(t, "worktrees", worktrees(t, filepath.Join(project, "main")), []string{filepath.Join(project, "main"), filepath.Join(project, "moved")})
+	output = mustRun(t, project, "doctor")
+	assertList(t, "failures", findings(output, "fail"), []string{})
+}
+
+func TestRelocate(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	outside := filepath.Join(dir, "outside")
+	git(t, filepath.Join(project, "main"), "worktree", "add", "--quiet", "-b", "out", outside)
+	moved := filepath.Join(dir, "moved")
+	// Paths into the project are rewritten wherever they end; a path that only
+	// starts with the project directory's is not.
+	config := "default: main\n" +
+		"post-mk: " + project + "/scripts/setup.sh\n" +
+		"post-mk: cp \"" + project + "\" '" + project + "';" + project + ":x\n" +
+		"pre-rm: echo " + project + "\n" +
+		"copy: " + project + "-other/env\n"
+	if err := os.WriteFile(filepath.Join(project, ".git-wt"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := mustRun(t, filepath.Join(project, "one"), "relocate", moved)
+
+	assertMissing(t, project)
+	assertExists(t, filepath.Join(moved, "main", "README"))
+	if !strings.Contains(output, "change to "+filepath.Join(moved, "one")) {
+		t.Errorf("relocate does not follow the current directory: %q", output)
+	}
+	got, err := os.ReadFile(filepath.Join(moved, ".git-wt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "default: main\n" +
+		"post-mk: " + moved + "/scripts/setup.sh\n" +
+		"post-mk: cp \"" + moved + "\" '" + moved + "';" + moved + ":x\n" +
+		"pre-rm: echo " + moved + "\n" +
+		"copy: " + project + "-other/env\n"
+	assertEqual(t, "config", string(got), want)
+	assertList(t, "worktrees", worktrees(t, filepath.Join(moved, "main")), []string{filepath.Join(moved, "main"), filepath.Join(moved, "one"), outside})
+	assertEqual(t, "linked worktree", git(t, filepath.Join(moved, "one"), "rev-parse", "--abbrev-ref", "HEAD"), "one")
+	assertEqual(t, "outside worktree", git(t, outside, "rev-parse", "--abbrev-ref", "HEAD"), "out")
+	mustRun(t, moved, "doctor")
+
+	// The destination must be free and outside the project.
+	for _, v := range []string{outside, filepath.Join(moved, "inner")} {
+		if _, err := run(t, moved, "relocate", v); err == nil {
+			t.Errorf("expected relocating to %s to fail", v)
+		}
+	}
+	assertExists(t, moved)
+}
+
+func TestDefault(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{Head: "master", Branches: []string{"main"}})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "master")
+	git(t, r.Dir, "symbolic-ref", "HEAD", "refs/heads/main")
+
+	mustRun(t, project, "default")
+
+	assertMissing(t, filepath.Join(project, "master"))
+	assertExists(t, filepath.Join(project, "main", "main.txt"))
+	assertList(t, "branches", branches(t, filepath.Join(project, "main")), []string{"main", "one"})
+	assertEqual(t, "upstream", git(t, filepath.Join(project, "main"), "rev-parse", "--abbrev-ref", "main@{upstream}"), "origin/main")
+	config, err := os.ReadFile(filepath.Join(project, ".git-wt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "config", string(config), "default: main\n")
+	assertEqual(t, "linked worktree", git(t, filepath.Join(project, "one"), "rev-parse", "--abbrev-ref", "HEAD"), "one")
+}
+
+func TestDefaultRollback(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{Head: "master", Branches: []string{"dev/main"}})
+	project := clone(t, dir, r)
+	git(t, r.Dir, "symbolic-ref", "HEAD", "refs/heads/dev/main")
+	// A file where the worktree's parent directory belongs stops the move.
+	if err := os.WriteFile(filepath.Join(project, "dev"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := run(t, project, "default")
+
+	if err == nil || !strings.Contains(err.Error(), "rolled back all 1 changes") {
+		t.Errorf("error does not report the rollback: %v", err)
+	}
+	assertExists(t, filepath.Join(project, "master", "README"))
+	assertList(t, "branches", branches(t, filepath.Join(project, "master")), []string{"master"})
+	assertEqual(t, "upstream", git(t, filepath.Join(project, "master"), "rev-parse", "--abbrev-ref", "master@{upstream}"), "origin/master")
+	config, err := os.ReadFile(filepath.Join(project, ".git-wt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, "config", string(config), "default: master\n")
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "dirty", "dirty", "main")
+	mustRun(t, project, "mk", "--lock", "-b", "locked", "locked", "main")
+	if err := os.WriteFile(filepath.Join(project, "dirty", "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		dir  string
+		args []string
+		code int
+	}{
+		{project, []string{"mk", "--bogus"}, cmn.ExitUsage},
+		{project, []string{"ls", "extra"}, cmn.ExitUsage},
+		{project, []string{"mk", ".hidden", "main"}, cmn.ExitUsage},
+		{dir, []string{"ls"}, cmn.ExitNoProject},
+		{project, []string{"rm", "missing"}, cmn.ExitNoWorktree},
+		{project, []string{"rm", "dirty"}, cmn.ExitWorktreeDirty},
+		{project, []string{"rm", "-f", "locked"}, cmn.ExitLocked},
+		{filepath.Join(project, "dirty"), []string{"rm", "-f", "dirty"}, cmn.ExitInside},
+		{project, []string{"mk", "-b", "dirty", "other", "main"}, cmn.ExitExists},
+		{dir, []string{"cl", r.URL}, cmn.ExitExists},
+		{dir, []string{"cl", "file://" + filepath.Join(dir, "missing.git")}, cmn.ExitRemote},
+		{project, []string{"--timeout", "-1s", "ls"}, cmn.ExitUsage},
+		{project, []string{"--timeout", "1ns", "ls"}, cmn.ExitTimeout},
+	} {
+		_, err := run(t, tt.dir, tt.args...)
+		assertEqual(t, "git-wt "+strings.Join(tt.args, " "), cmn.ExitCode(err), tt.code)
+	}
+}
+
+func TestProjectLock(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	lockFile := filepath.Join(project, ".git-wt.d", "lock")
+	host, _ := os.Hostname()
+	hold := func(pid int) {
+		t.Helper()
+		content, err := json.Marshal(cmn.LockHolder{PID: pid, Host: host, Command: "git-wt xx --all", Since: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(lockFile, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Held by a running process.
+	hold(os.Getpid())
+	_, err := run(t, project, "mk", "-b", "one", "one", "main")
+	assertEqual(t, "exit code", cmn.ExitCode(err), cmn.ExitProjectLocked)
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("PID %d", os.Getpid())) || !strings.Contains(err.Error(), "xx --all") {
+		t.Errorf("error does not name the holder: %v", err)
+	}
+	assertMissing(t, filepath.Join(project, "one"))
+	_, err = run(t, project, "relocate", filepath.Join(dir, "moved"))
+	assertEqual(t, "relocate exit code", cmn.ExitCode(err), cmn.ExitProjectLocked)
+	assertMissing(t, filepath.Join(dir, "moved"))
+	_, err = run(t, project, "default")
+	assertEqual(t, "default exit code", cmn.ExitCode(err), cmn.ExitProjectLocked)
+	_, err = run(t, project, "lock", "main")
+	assertEqual(t, "lock exit code", cmn.ExitCode(err), cmn.ExitProjectLocked)
+	_, err = run(t, project, "sync")
+	assertEqual(t, "sync exit code", cmn.ExitCode(err), cmn.ExitProjectLocked)
+	_, err = run(t, project, "repair")
+	assertEqual(t, "repair exit code", cmn.ExitCode(err), cmn.ExitProjectLocked)
+
+	// Released while waiting.
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		os.Remove(lockFile)
+	}()
+	mustRun(t, project, "--lock-wait", "5s", "mk", "-b", "one", "one", "main")
+	assertExists(t, filepath.Join(project, "one"))
+	assertMissing(t, lockFile)
+
+	// Left behind by a process that has exited.
+	exited := exec.Command("git", "version")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	hold(exited.Process.Pid)
+	mustRun(t, project, "rm", "one")
+	assertMissing(t, filepath.Join(project, "one"))
+	assertMissing(t, lockFile)
+
+	// Moved along with the project, and released there.
+	moved := filepath.Join(dir, "moved")
+	mustRun(t, project, "relocate", moved)
+	assertMissing(t, filepath.Join(moved, ".git-wt.d", "lock"))
+}
+
+func TestUndo(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+
+	if _, err := run(t, project, "undo"); err == nil {
+		t.Error("expected undo without a journal to fail")
+	}
+
+	// Adding a worktree with a new branch.
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	mustRun(t, project, "undo", "-n")
+	assertExists(t, filepath.Join(project, "one"))
+	mustRun(t, project, "undo")
+	assertMissing(t, filepath.Join(project, "one"))
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main"})
+
+	// Adding a worktree resetting a branch.
+	mustRun(t, project, "mk", "-b", "reset", "reset", "main")
+	r.commit(filepath.Join(project, "reset"), "work.txt", "work\n")
+	head := git(t, filepath.Join(project, "reset"), "rev-parse", "HEAD")
+	mustRun(t, project, "rm", "reset")
+	mustRun(t, project, "mk", "-B", "reset", "reset", "main")
+	mustRun(t, project, "undo")
+	assertMissing(t, filepath.Join(project, "reset"))
+	assertEqual(t, "head", git(t, project+"/main", "rev-parse", "reset"), head)
+	journal, err := os.ReadFile(filepath.Join(project, ".git-wt.d", "journal.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(journal), `"kind":"reset-branch","branch":"reset","head":"`+head+`"`) {
+		t.Errorf("undo did not record the branch reset: %s", journal)
+	}
+	git(t, project+"/main", "branch", "-D", "reset")
+
+	// Removing a worktree, then moving one.
+	mustRun(t, project, "mk", "-b", "two", "two", "main")
+	r.commit(filepath.Join(project, "two"), "work.txt", "work\n")
+	head = git(t, filepath.Join(project, "two"), "rev-parse", "HEAD")
+	mustRun(t, project, "mk", "-b", "three", "three", "main")
+	mustRun(t, project, "rm", "two")
+	mustRun(t, project, "mv", "--branch", "three", "renamed")
+
+	mustRun(t, project, "undo")
+	assertMissing(t, filepath.Join(project, "renamed"))
+	assertEqual(t, "branch", git(t, filepath.Join(project, "three"), "rev-parse", "--abbrev-ref", "HEAD"), "three")
+	mustRun(t, project, "undo")
+	assertEqual(t, "head", git(t, filepath.Join(project, "two"), "rev-parse", "HEAD"), head)
+	assertExists(t, filepath.Join(project, "two", "work.txt"))
+
+	// Resetting most of the project.
+	mustRun(t, project, "xx", "-m")
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main"})
+	mustRun(t, project, "undo")
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main", "three", "two"})
+	assertEqual(t, "head", git(t, filepath.Join(project, "two"), "rev-parse", "HEAD"), head)
+	assertExists(t, filepath.Join(project, "three"))
+
+	// Work made since is not lost unless forced.
+	if err := os.WriteFile(filepath.Join(project, "three", "new.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, project, "undo"); err == nil {
+		t.Error("expected undoing over uncommitted changes to fail")
+	}
+	assertExists(t, filepath.Join(project, "three", "new.txt"))
+
+	// Resetting everything cannot be undone.
+	mustRun(t, project, "xx", "-a")
+	if _, err := run(t, project, "undo"); err == nil {
+		t.Error("expected undoing xx --all to fail")
+	}
+}
+
+func TestUndoRollback(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	mustRun(t, project, "mk", "-b", "one", "one", "main")
+	mustRun(t, project, "mk", "-b", "two", "two", "main")
+	mustRun(t, project, "xx", "-m")
+	// A stale ref lock makes creating branch one again fail, after branch two.
+	lock := filepath.Join(project, "main", ".git", "refs", "heads", "one.lock")
+	if err := os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := run(t, project, "undo")
+
+	if err == nil || !strings.Contains(err.Error(), "rolled back all 1 changes") {
+		t.Errorf("error does not report the roll back: %v", err)
+	}
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main")})
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main"})
+
+	// The journal still matches the project, so undo can be retried.
+	os.Remove(lock)
+	mustRun(t, project, "undo")
+	assertList(t, "worktrees", worktrees(t, project+"/main"), []string{filepath.Join(project, "main"), filepath.Join(project, "one"), filepath.Join(project, "two")})
+	assertList(t, "branches", branches(t, project+"/main"), []string{"main", "one", "two"})
+}
+
+func TestHookOutput(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	config, err := os.OpenFile(filepath.Join(project, ".git-wt"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(config, "hook-failure: warn\npost-mk: echo made $GIT_WT_WORKTREE\npre-rm: echo failing >&2; false\n")
+	config.Close()
+
+	// Hook output and warnings go to the output of the command.
+	output := mustRun(t, project, "mk", "-b", "one", "one", "main")
+	if !strings.Contains(output, "made one") {
+		t.Errorf("hook output missing: %q", output)
+	}
+	output = mustRun(t, project, "rm", "one")
+	if !strings.Contains(output, "failing") || !strings.Contains(output, "warning: pre-rm hook failed") {
+		t.Errorf("hook warning missing: %q", output)
+	}
+	assertMissing(t, filepath.Join(project, "one"))
+}
+
+func TestHookTimeout(t *testing.T) {
+	dir := hermetic(t)
+	r := newRemote(t, dir, remoteOptions{})
+	project := clone(t, dir, r)
+	config, err := os.OpenFile(filepath.Join(project, ".git-wt"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(config, "hook-failure: warn\npost-mk: exec sleep 30\npost-mk: touch $GIT_WT_WORKTREE_PATH/after\n")
+	config.Close()
+
+	// A hook past the timeout is killed and fails the command, whatever the
+	// hook-failure policy; the hooks after it do not run.
+	start := time.Now()
+	_, err = run(t, project, "--timeout", "2s", "mk", "-b", "one", "one", "main")
+
+	assertEqual(t, "exit code", cmn.ExitCode(err), cmn.ExitTimeout)
+	if elapsed := time.Since(start); elapsed > 15*time.Second {
+		t.Errorf("hook not killed at the timeout; took %s", elapsed)
+	}
+	assertMissing(t, filepath.Join(project, "one", "after"))
+}
@@ -0,0 +1,92 @@
+// Package shellinit implements the shell-init subcommand for git-wt.
+package shellinit
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "shell-init" // Command name.
+
+	posix = `# {{name}} shell integration for {{shell}}.
+{{name}}() {
+  local __git_wt_cd __git_wt_rc
+  __git_wt_cd="$(mktemp "${TMPDIR:-/tmp}/{{name}}.XXXXXX")" || return
+  {{env}}="$__git_wt_cd" command {{name}} "$@"
+  __git_wt_rc=$?
+  if [ -s "$__git_wt_cd" ]; then
+    builtin cd -- "$(cat "$__git_wt_cd")" || __git_wt_rc=$?
+  fi
+  rm -f -- "$__git_wt_cd"
+  return $__git_wt_rc
+}
+git() {
+  if [ "$1" = "wt" ]; then
+    shift
+    {{name}} "$@"
+  else
+    command git "$@"
+  fi
+}
+` // Shell integration for bash and zsh.
+
+	fish = `# {{name}} shell integration for fish.
+function {{name}}
+  set -l __git_wt_cd (mktemp "$TMPDIR/{{name}}.XXXXXX" 2>/dev/null; or mktemp)
+  or return
+  env {{env}}=$__git_wt_cd command {{name}} $argv
+  set -l __git_wt_rc $status
+  if test -s $__git_wt_cd
+    builtin cd (cat $__git_wt_cd); or set __git_wt_rc $status
+  end
+  rm -f -- $__git_wt_cd
+  return $__git_wt_rc
+end
+function git --wraps git
+  if test "$argv[1]" = wt
+    {{name}} $argv[2..-1]
+  else
+    command git $argv
+  end
+end
+` // Shell integration for fish.
+)
+
+// New returns the cobra command for 'shell-init'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:       command + " bash|zsh|fish",
+		Short:     "Print shell integration for changing to worktrees.",
+		Long:      cmn.Basename + " " + command + " - Print shell integration for changing to worktrees.\n\nAdd to your shell startup file:\n\n  bash:  eval \"$(git wt shell-init bash)\"\n  zsh:   eval \"$(git wt shell-init zsh)\"\n  fish:  git wt shell-init fish | source",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		RunE:      run,
+	} // Cobra command definition for the 'shell-init' command.
+
+	return cmd
+}
+
+// run is the main function for the 'shell-init' command.
+func run(cmd *cobra.Command, args []string) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "args: %v", args)
+
+	script := posix
+	if args[0] == "fish" {
+		script = fish
+	}
+
+	fmt.Print(strings.NewReplacer(
+		"{{name}}", cmn.Basename,
+		"{{shell}}", args[0],
+		"{{env}}", cmn.CdFileEnv,
+	).Replace(script))
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package sync implements the sync subcommand for git-wt.
+package sync
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "sync" // Command name.
+)
+
+// New returns the cobra command for 'sync', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgSync{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:   command,
+		Short: "Update the worktrees of the project from the remote.",
+		Long: cmn.Basename + " " + command + " - Update the worktrees of the project from the remote.\n\n" +
+			"Fetches the remote once and fast-forwards the default worktree. With\n" +
+			"--rebase, --merge or --ff-only the other worktrees are then updated onto the\n" +
+			"remote default branch. Worktrees with uncommitted changes are skipped, and a\n" +
+			"rebase or merge that conflicts is aborted and reported.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
+	} // Cobra command definition for the 'sync' command.
+
+	cmd.PersistentFlags().BoolVar(&config.Rebase, "rebase", false, "rebase the other worktrees onto the default branch")
+	cmd.PersistentFlags().BoolVar(&config.Merge, "merge", false, "merge the default branch into the other worktrees")
+	cmd.PersistentFlags().BoolVar(&config.FfOnly, "ff-only", false, "fast-forward the other worktrees to the default branch")
+	cmd.MarkFlagsMutuallyExclusive("rebase", "merge", "ff-only")
+
+	return cmd
+}
+
+// run is the main function for the 'sync' command.
+func run(cmd *cobra.Command, args []string, config *cmn.CfgSync) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+	cmn.Debug(funcName, "config: %#v", config)
+
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	results, err := project.Sync(cmd.Context(), wt.SyncOptions{
+		Rebase: config.Rebase,
+		Merge:  config.Merge,
+		FfOnly: config.FfOnly,
+		Out:    os.Stdout,
+	})
+
+	// Print the summary of the worktrees visited.
+	if len(results) > 0 {
+		table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "\nWORKTREE\tRESULT")
+		for _, v := range results {
+			fmt.Fprintf(table, "%s\t%s\n", v.Worktree.Name, v.Status)
+		}
+		table.Flush()
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
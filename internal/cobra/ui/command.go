@@ -0,0 +1,68 @@
+// Package ui implements the ui subcommand for git-wt.
+package ui
+
+import (
+	"fmt"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/ui"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "ui" // Command name.
+)
+
+// New returns the cobra command for 'ui'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     command,
+		Short:   "Show a terminal dashboard for the project.",
+		Long:    cmn.Basename + " " + command + " - Show a terminal dashboard for the project.",
+		Args:    cobra.NoArgs,
+		Aliases: []string{"tui"},
+		RunE:    run,
+	} // Cobra command definition for the 'ui' command.
+
+	return cmd
+}
+
+// run is the main function for the 'ui' command.
+func run(cmd *cobra.Command, args []string) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	chosenWt, chosen, err := ui.Run(ctx, project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	// Change to the worktree chosen with Enter.
+	if chosen {
+		ok, err := project.Switch(ctx, chosenWt.Path)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		if !ok {
+			fmt.Println(chosenWt.Path)
+		}
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package undo implements the undo subcommand for git-wt.
+package undo
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "undo" // Command name.
+)
+
+// New returns the cobra command for 'undo', with a configuration of its own.
+func New() *cobra.Command {
+	config := &cmn.CfgUndo{} // Configuration for the command.
+	cmd := &cobra.Command{
+		Use:   command,
+		Short: "Undo the last change to the project.",
+		Long: cmn.Basename + " " + command + " - Undo the last change to the project.\n\n" +
+			"Reverses the latest mk, mv, rm or xx not undone yet, as recorded in the\n" +
+			"project's journal; undoing again reverses the one before. Force once to\n" +
+			"remove a worktree with uncommitted changes or delete a branch with new\n" +
+			"commits, and twice for a worktree locked since. 'xx --all' cannot be undone.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return run(cmd, args, config)
+		},
+	} // Cobra command definition for the 'undo' command.
+
+	cmd.PersistentFlags().CountVarP(&config.Force, "force", "f", "force undoing even if work would be lost; twice if locked")
+	cmd.PersistentFlags().BoolVarP(&config.DryRun, "dry-run", "n", false, "show what would be undone")
+
+	return cmd
+}
+
+// run is the main function for the 'undo' command.
+func run(cmd *cobra.Command, args []string, config *cmn.CfgUndo) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	wtProject, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project: %#v", wtProject)
+
+	cmn.Debug(funcName, "config: %#v", config)
+
+	wtProject.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	err = wtProject.Undo(cmd.Context(), wt.UndoOptions{
+		Force:  config.Force,
+		DryRun: config.DryRun,
+		Out:    os.Stdout,
+	})
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package unlock implements the unlock subcommand for git-wt.
+package unlock
+
+import (
+	"fmt"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"github.com/spf13/cobra"
+)
+
+var (
+	command = "unlock" // Command name.
+)
+
+// New returns the cobra command for 'unlock'.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   command + " [worktree_name]",
+		Short: "Unlock a worktree of the project.",
+		Long:  cmn.Basename + " " + command + " - Unlock a worktree of the project.\n\nThe worktree is picked interactively if omitted.",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE:  run,
+	} // Cobra command definition for the 'unlock' command.
+
+	return cmd
+}
+
+// run is the main function for the 'unlock' command.
+func run(cmd *cobra.Command, args []string) error {
+	funcName := command + ".run"
+	cmn.Trace(funcName, "begin")
+
+	ctx := cmd.Context()
+
+	// Open the project.
+	cmn.Debug(funcName, "opening project")
+	project, err := wt.Open(".")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error loading configuration: %w", err)
+	}
+	cmn.Debug(funcName, "project config: %#v", project)
+	cmn.Debug(funcName, "args: %v", args)
+
+	project.LockWait, _ = cmd.Flags().GetDuration(cmn.LockWaitFlag)
+
+	// Resolve the worktree, picking it if not given.
+	found := wt.Worktree{}
+	if len(args) == 0 {
+		found, err = project.Pick(ctx, command+"> ")
+	} else {
+		found, err = project.Find(ctx, args[0])
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	cmn.Debug(funcName, "worktree: %s", found.Name)
+
+	unlocked, err := project.Unlock(ctx, found.Name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	fmt.Printf("Unlocked worktree: %s\n", unlocked.Name)
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package files implements carrying untracked local files, such as ignored
+// environment files and editor settings, from the default worktree into new
+// worktrees.
+package files
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+)
+
+// Carry copies, links or symlinks the files matching the project's copy
+// patterns from the src worktree into the dst worktree, returning the paths
+// carried relative to the worktree. Existing files in dst are left untouched.
+func Carry(project *cmn.Project, src string, dst string) ([]string, error) {
+	funcName := "files.Carry"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "src: %s; dst: %s; mode: %s", src, dst, project.CopyMode)
+
+	carried := []string{}
+	for _, pattern := range project.Copy {
+		cmn.Debug(funcName, "pattern: %s", pattern)
+
+		matches, err := filepath.Glob(filepath.Join(src, filepath.FromSlash(pattern)))
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return carried, fmt.Errorf("invalid copy pattern %s: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			rel, err := filepath.Rel(src, match)
+			if err != nil || rel == "." || !filepath.IsLocal(rel) {
+				cmn.Debug(funcName, "match outside source worktree; skipping: %s", match)
+				continue
+			}
+
+			target := filepath.Join(dst, rel)
+			if _, err := os.Lstat(target); err == nil {
+				cmn.Debug(funcName, "target exists; skipping: %s", target)
+				continue
+			}
+
+			cmn.Debug(funcName, "carrying: %s", rel)
+			err = os.MkdirAll(filepath.Dir(target), 0755)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return carried, fmt.Errorf("could not create directory for %s: %w", rel, err)
+			}
+
+			if project.CopyMode == cmn.CopyModeSymlink {
+				err = symlink(match, target)
+			} else {
+				err = carryTree(match, target, project.CopyMode)
+			}
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return carried, fmt.Errorf("could not carry %s: %w", rel, err)
+			}
+			carried = append(carried, rel)
+		}
+	}
+	cmn.Debug(funcName, "carried: %v", carried)
+
+	cmn.Trace(funcName, "end")
+	return carried, nil
+}
+
+// symlink creates a relative symlink at target pointing to path.
+func symlink(path string, target string) error {
+	link, err := filepath.Rel(filepath.Dir(target), path)
+	if err != nil {
+		link = path
+	}
+	return os.Symlink(link, target)
+}
+
+// carryTree carries a file or directory tree from path to target using the
+// given copy mode.
+func carryTree(path string, target string, mode string) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return err
+		}
+		out := filepath.Join(target, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(out, info.Mode().Perm())
+		case d.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, out)
+		case !d.Type().IsRegular():
+			cmn.Debug("files.carryTree", "skipping irregular file: %s", p)
+			return nil
+		}
+
+		switch mode {
+		case cmn.CopyModeHardlink:
+			return os.Link(p, out)
+		case cmn.CopyModeReflink:
+			if reflink(p, out, info.Mode().Perm()) == nil {
+				return nil
+			}
+			cmn.Debug("files.carryTree", "reflink unsupported; copying: %s", p)
+		}
+		return copyFile(p, out, info.Mode().Perm())
+	})
+}
+
+// copyFile copies the contents of a regular file.
+func copyFile(path string, target string, perm fs.FileMode) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,130 @@
+package files
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+)
+
+// writeFile writes a file with the mode, creating its directory.
+func writeFile(t *testing.T, path string, contents string, perm fs.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCarry(t *testing.T) {
+	tests := []struct {
+		mode    string
+		symlink bool // Whether carried files are symlinks to the source.
+		shared  bool // Whether carried files are the source files, as with hard links.
+	}{
+		{mode: cmn.CopyModeCopy},
+		{mode: cmn.CopyModeHardlink, shared: true},
+		{mode: cmn.CopyModeReflink}, // Falls back to copying where unsupported.
+		{mode: cmn.CopyModeSymlink, symlink: true, shared: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, "main")
+			dst := filepath.Join(dir, "feature")
+			writeFile(t, filepath.Join(src, ".env"), "secret\n", 0600)
+			writeFile(t, filepath.Join(src, "conf", "local.json"), "{}\n", 0640)
+			writeFile(t, filepath.Join(src, "run.sh"), "#!/bin/sh\n", 0755)
+			project := &cmn.Project{Copy: []string{".env", "conf", "*.sh"}, CopyMode: tt.mode}
+
+			carried, err := Carry(project, src, dst)
+
+			if err != nil {
+				t.Fatalf("Carry: %v", err)
+			}
+			want := []string{".env", "conf", "run.sh"}
+			if !slices.Equal(carried, want) {
+				t.Errorf("carried = %v, want %v", carried, want)
+			}
+			for _, v := range []string{".env", filepath.Join("conf", "local.json"), "run.sh"} {
+				source, err := os.Stat(filepath.Join(src, v))
+				if err != nil {
+					t.Fatal(err)
+				}
+				target, err := os.Stat(filepath.Join(dst, v))
+				if err != nil {
+					t.Errorf("%s not carried: %v", v, err)
+					continue
+				}
+				if target.Mode() != source.Mode() {
+					t.Errorf("%s: mode = %v, want %v", v, target.Mode(), source.Mode())
+				}
+				if os.SameFile(source, target) != tt.shared {
+					t.Errorf("%s: same file as source = %v, want %v", v, !tt.shared, tt.shared)
+				}
+			}
+			link, err := os.Lstat(filepath.Join(dst, ".env"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (link.Mode()&fs.ModeSymlink != 0) != tt.symlink {
+				t.Errorf(".env: symlink = %v, want %v", !tt.symlink, tt.symlink)
+			}
+		})
+	}
+}
+
+func TestCarrySkipsExisting(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main")
+	dst := filepath.Join(dir, "feature")
+	writeFile(t, filepath.Join(src, ".env"), "source\n", 0644)
+	writeFile(t, filepath.Join(src, ".tool"), "source\n", 0644)
+	writeFile(t, filepath.Join(dst, ".env"), "existing\n", 0644)
+	project := &cmn.Project{Copy: []string{".env", ".tool"}, CopyMode: cmn.CopyModeCopy}
+
+	carried, err := Carry(project, src, dst)
+
+	if err != nil {
+		t.Fatalf("Carry: %v", err)
+	}
+	if !slices.Equal(carried, []string{".tool"}) {
+		t.Errorf("carried = %v, want [.tool]", carried)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dst, ".env")); string(got) != "existing\n" {
+		t.Errorf("existing file overwritten: %q", got)
+	}
+}
+
+func TestCarryRejectsOutside(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "main")
+	dst := filepath.Join(dir, "feature")
+	writeFile(t, filepath.Join(dir, "outside.env"), "outside\n", 0644)
+	writeFile(t, filepath.Join(src, ".env"), "inside\n", 0644)
+	project := &cmn.Project{Copy: []string{"../*.env", "..", ".", "*.env", ".env"}, CopyMode: cmn.CopyModeCopy}
+
+	carried, err := Carry(project, src, dst)
+
+	if err != nil {
+		t.Fatalf("Carry: %v", err)
+	}
+	if !slices.Equal(carried, []string{".env"}) {
+		t.Errorf("carried = %v, want [.env]", carried)
+	}
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != ".env" {
+		t.Errorf("matches outside the source worktree carried: %v", entries)
+	}
+}
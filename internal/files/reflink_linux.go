@@ -0,0 +1,35 @@
+//go:build linux
+
+package files
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+const (
+	ficlone = 0x40049409 // FICLONE ioctl request; see ioctl_ficlone(2).
+)
+
+// reflink clones a file with copy-on-write where the filesystem supports it.
+func reflink(path string, target string, perm fs.FileMode) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	out.Close()
+	if errno != 0 {
+		os.Remove(target)
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package files
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// reflink is not supported on this platform; callers fall back to copying.
+func reflink(path string, target string, perm fs.FileMode) error {
+	return errors.ErrUnsupported
+}
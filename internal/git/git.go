@@ -1,364 +1,1220 @@
 // Package git implements abstraction to the module git-module. It also provides
-// for worktree commands not implemented in git-module.
+// for worktree commands not implemented in git-module. Every command runs
+// through the project's cmn.Runner, so tests may script git with gittest.Fake.
 package git
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
-	"github.com/gogs/git-module"
 	"github.com/jason-dour/git-wt/internal/cmn"
 )
 
+type (
+	Worktree struct {
+		Path       string // Absolute path of the worktree.
+		Name       string // Path of the worktree relative to the project directory.
+		Head       string // Commit id checked out in the worktree.
+		Branch     string // Full ref name of the checked out branch; empty if detached.
+		Bare       bool   // Whether the worktree is the bare repository.
+		Detached   bool   // Whether the worktree has a detached HEAD.
+		Locked     bool   // Whether the worktree is locked.
+		LockReason string // Reason given when the worktree was locked.
+		Prunable   bool   // Whether the worktree can be pruned.
+	} // Worktree as reported by 'git worktree list --porcelain'.
+)
+
+const (
+	RefsHeads = "refs/heads/" // Prefix of full ref names for local branches.
+)
+
+// BranchName returns the short name of the branch checked out in the worktree.
+func (w Worktree) BranchName() string {
+	return strings.TrimPrefix(w.Branch, RefsHeads)
+}
+
+// BranchLabel returns the branch shown for the worktree in lists, or
+// "(detached)" if it has none.
+func (w Worktree) BranchLabel() string {
+	if w.Detached || w.Branch == "" {
+		return "(detached)"
+	}
+	return w.BranchName()
+}
+
+// InProject reports whether the worktree lives beneath the project directory.
+func (w Worktree) InProject() bool {
+	return w.Name != "" && w.Name != "." && w.Name != ".." && !strings.HasPrefix(w.Name, ".."+string(filepath.Separator))
+}
+
+// Contains reports whether path is the worktree's directory or beneath it.
+func (w Worktree) Contains(path string) bool {
+	return isWithin(path, w.Path)
+}
+
+// isWithin reports whether path is dir or is beneath dir.
+func isWithin(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// runDir returns the directory to run worktree commands in; the initial
+// directory if it is within a worktree, otherwise the default worktree.
+func runDir(project *cmn.Project) string {
+	for dir := project.InitialDir; dir != project.ProjectDir && isWithin(dir, project.ProjectDir); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return project.InitialDir
+		}
+	}
+	return filepath.Join(project.ProjectDir, project.DefaultBranch)
+}
+
+// pruneEmptyDirs removes empty parent directories of a worktree path, stopping
+// at the project directory.
+func pruneEmptyDirs(project *cmn.Project, path string) {
+	funcName := "git.pruneEmptyDirs"
+	cmn.Trace(funcName, "begin")
+
+	for dir := filepath.Dir(path); dir != project.ProjectDir && isWithin(dir, project.ProjectDir); dir = filepath.Dir(dir) {
+		// os.Remove only removes empty directories.
+		if os.Remove(dir) != nil {
+			break
+		}
+		cmn.Debug(funcName, "removed empty directory: %s", dir)
+	}
+
+	cmn.Trace(funcName, "end")
+}
+
 // Clone will clone a git repository, checkout a branch, to a path provided.
-func Clone(url string, branch string, path string) error {
+// The project may be nil, as there is none before the first clone.
+func Clone(ctx context.Context, project *cmn.Project, url string, branch string, path string) error {
 	funcName := "git.Clone"
-	cmn.Debug("%s: begin", funcName)
+	cmn.Trace(funcName, "begin")
 
-	// If Debug, set debug for git-module.
-	if cmn.Config.DebugFlag {
-		git.SetOutput(os.Stderr)
-		git.SetPrefix("debug: git-module: ")
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not create parent directory: %w", err)
 	}
 
-	err := git.Clone(url, path, git.CloneOptions{
-		Branch: branch,
-	})
+	args := []string{"clone", "-b", branch, "--", url, path}
+
+	_, err = runner(project).Run(ctx, "", args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
-		return fmt.Errorf("could not clone repo: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: could not clone repo: %w", cmn.ErrRemote, err)
 	}
 
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
 	return nil
 }
 
 // DeleteBranch will delete a local branch from the repository.
-func DeleteBranch(branch string) error {
+func DeleteBranch(ctx context.Context, project *cmn.Project, branch string) error {
 	funcName := "git.DeleteBranch"
-	cmn.Debug("%s: begin", funcName)
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"branch", "-D", branch}
+
+	_, err := runner(project).Run(ctx, filepath.Join(project.ProjectDir, project.DefaultBranch), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error deleting branch: %w", err)
+	}
 
-	// If Debug, set debug for git-module.
-	if cmn.Config.DebugFlag {
-		git.SetOutput(os.Stderr)
-		git.SetPrefix("debug: git-module: ")
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// CreateBranch will create a local branch at a commit, or move an existing
+// one there if force is set.
+func CreateBranch(ctx context.Context, project *cmn.Project, branch string, commit string, force bool) error {
+	funcName := "git.CreateBranch"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"branch"}
+	if force {
+		args = append(args, "--force")
 	}
+	args = append(args, branch, commit)
 
-	err := git.DeleteBranch(
-		filepath.Join(cmn.Config.ProjectDir, cmn.Config.DefaultBranch),
-		branch,
-		git.DeleteBranchOptions{
-			Force: true,
-		})
+	_, err := runner(project).Run(ctx, runDir(project), args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
-		return fmt.Errorf("error deleting branch: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not create branch %s at %s: %w", branch, commit, err)
 	}
 
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
 	return nil
 }
 
-// GetBranches will retrieve local branches from the repository.
-func GetBranches() ([]string, error) {
-	funcName := "git.GetBranches"
-	cmn.Debug("%s: begin", funcName)
+// RenameBranch will rename a local branch, including when it is checked out
+// in a worktree.
+func RenameBranch(ctx context.Context, project *cmn.Project, branch string, newBranch string) error {
+	funcName := "git.RenameBranch"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"branch"}
+	args = append(args, "-m", branch, newBranch)
+
+	_, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not rename branch %s to %s: %w", branch, newBranch, err)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// SetUpstream will set the upstream of a local branch, or unset it if
+// upstream is empty.
+func SetUpstream(ctx context.Context, project *cmn.Project, branch string, upstream string) error {
+	funcName := "git.SetUpstream"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"branch"}
+	if len(upstream) > 0 {
+		args = append(args, "--set-upstream-to", upstream, branch)
+	} else {
+		args = append(args, "--unset-upstream", branch)
+	}
+
+	_, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not set upstream of %s: %w", branch, err)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// SetRemoteHead will update the remote's HEAD ref from the remote.
+func SetRemoteHead(ctx context.Context, project *cmn.Project) error {
+	funcName := "git.SetRemoteHead"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"remote"}
+	args = append(args, "set-head", project.Remote, "--auto")
+
+	_, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: could not update HEAD of remote %s: %w", cmn.ErrRemote, project.Remote, err)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// RefExists will report whether a ref exists in the repository.
+func RefExists(ctx context.Context, project *cmn.Project, ref string) bool {
+	funcName := "git.RefExists"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"rev-parse"}
+	args = append(args, "--verify", "--quiet", ref)
 
-	repository, err := git.Open(filepath.Join(cmn.Config.ProjectDir, cmn.Config.DefaultBranch))
+	_, err := runner(project).Run(ctx, runDir(project), args...)
+	cmn.Debug(funcName, "exists: %v", err == nil)
+
+	cmn.Trace(funcName, "end")
+	return err == nil
+}
+
+// RevParse will resolve a ref to the id of the commit it points at.
+func RevParse(ctx context.Context, project *cmn.Project, ref string) (string, error) {
+	funcName := "git.RevParse"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"rev-parse"}
+	args = append(args, "--verify", "--quiet", ref+"^{commit}")
+
+	output, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("could not resolve %s: %w", ref, err)
+	}
+	id := strings.TrimSpace(string(output))
+	cmn.Debug(funcName, "%s: %s", ref, id)
+
+	cmn.Trace(funcName, "end")
+	return id, nil
+}
+
+// Fetch will fetch the project's configured remote.
+func Fetch(ctx context.Context, project *cmn.Project) ([]byte, error) {
+	funcName := "git.Fetch"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"fetch"}
+	args = append(args, project.Remote)
+
+	output, err := runner(project).Run(ctx, filepath.Join(project.ProjectDir, project.DefaultBranch), args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
-		return []string{}, fmt.Errorf("error opening repository: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("%w: could not fetch remote %s: %w", cmn.ErrRemote, project.Remote, err)
 	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// GetMergedBranches will retrieve local branches merged into a ref.
+func GetMergedBranches(ctx context.Context, project *cmn.Project, ref string) ([]string, error) {
+	funcName := "git.GetMergedBranches"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"branch"}
+	args = append(args, "--format=%(refname:short)", "--merged", ref)
+
+	output, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return []string{}, fmt.Errorf("could not list branches merged into %s: %w", ref, err)
+	}
+
+	branches := strings.Fields(string(output))
+	cmn.Debug(funcName, "branches: %v", branches)
+
+	cmn.Trace(funcName, "end")
+	return branches, nil
+}
+
+// GetBranches will retrieve local branches from the repository.
+func GetBranches(ctx context.Context, project *cmn.Project) ([]string, error) {
+	funcName := "git.GetBranches"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"for-each-ref", "--format=%(refname:short)", RefsHeads}
 
-	branches, err := repository.Branches()
+	output, err := runner(project).Run(ctx, filepath.Join(project.ProjectDir, project.DefaultBranch), args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
-		return []string{}, fmt.Errorf("error getting branches: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return []string{}, fmt.Errorf("error getting branches: %w", err)
 	}
-	cmn.Debug("%s: branches: %v", funcName, branches)
 
-	cmn.Debug("%s: end", funcName)
+	branches := strings.Fields(string(output))
+	cmn.Debug(funcName, "branches: %v", branches)
+
+	cmn.Trace(funcName, "end")
 	return branches, nil
 }
 
-// GetRemote will get the remote URL for the origin.
-func GetRemote(directory string) (string, error) {
+// GetRemote will get the remote URL for the project's configured remote.
+func GetRemote(ctx context.Context, project *cmn.Project, directory string) (string, error) {
 	funcName := "git.getRemote"
-	cmn.Debug("%s: begin", funcName)
+	cmn.Trace(funcName, "begin")
 
-	// g remote get-url origin
+	// g remote get-url <remote>
 
-	cmd := git.NewCommand("remote")
-	cmd.AddArgs("get-url", "origin")
+	remoteName := project.Remote
+	if remoteName == "" {
+		remoteName = cmn.DefaultRemote
+	}
 
-	cmn.Debug("%s: command: %s", funcName, cmd.String())
+	args := []string{"remote"}
+	args = append(args, "get-url", remoteName)
 
-	output, err := cmd.RunInDir(directory)
+	output, err := runner(project).Run(ctx, directory, args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
+		cmn.Trace(funcName, "error: end")
 		return "", err
 	}
-	cmn.Debug("%s: output length: %d", funcName, len(output))
+	cmn.Debug(funcName, "output length: %d", len(output))
 
 	remote := ""
 	scanner := bufio.NewScanner(strings.NewReader(string(output)))
 	for scanner.Scan() {
-		cmn.Debug("%s: output line: %s", funcName, scanner.Text())
+		cmn.Debug(funcName, "output line: %s", scanner.Text())
 		remote = scanner.Text()
 		break
 	}
-	cmn.Debug("%s: remote: %s", funcName, remote)
+	cmn.Debug(funcName, "remote: %s", remote)
 
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
 	return remote, nil
 }
 
-// GetRemoteDefaultBranch will retrieve the default branch from a remote git repository.
-func GetRemoteDefaultBranch(url string) (string, error) {
+// GetRemoteDefaultBranch will retrieve the default branch from a remote git
+// repository. The project may be nil.
+func GetRemoteDefaultBranch(ctx context.Context, project *cmn.Project, url string) (string, error) {
 	funcName := "git.GetRemoteDefaultBranch"
-	cmn.Debug("%s: begin", funcName)
-
-	// If Debug, set debug for git-module.
-	if cmn.Config.DebugFlag {
-		git.SetOutput(os.Stderr)
-		git.SetPrefix("debug: git-module: ")
-	}
+	cmn.Trace(funcName, "begin")
 
 	// Initialize the return value.
 	defaultBranch := ""
 
 	// Run ls-remote to grab HEAD symref.
-	refs, err := git.LsRemote(url, git.LsRemoteOptions{
-		Patterns: []string{
-			"HEAD",
-		},
-		CommandOptions: git.CommandOptions{
-			Args: []string{
-				"--symref",
-			},
-		},
-	})
+	refs, err := lsRemote(ctx, project, url, "HEAD", true)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
-		return "", fmt.Errorf("could not retrieve HEAD ref from remote: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("%w: could not retrieve HEAD ref from remote: %w", cmn.ErrRemote, err)
 	}
 
 	// Iterate through returned refs.
 	for i, v := range refs {
-		cmn.Debug("%s: refs[%v]: { id: %v, refspec: %v }", funcName, i, v.ID, v.Refspec)
+		cmn.Debug(funcName, "refs[%v]: { id: %v, refspec: %v }", i, v.ID, v.Refspec)
 		if v.ID == "ref:" {
-			defaultBranch = filepath.Base(v.Refspec)
-			cmn.Debug("%s: found default branch: %s", funcName, defaultBranch)
+			defaultBranch = strings.TrimPrefix(v.Refspec, RefsHeads)
+			cmn.Debug(funcName, "found default branch: %s", defaultBranch)
 		}
 	}
-	cmn.Debug("%s: defaultBranch: %v", funcName, defaultBranch)
+	cmn.Debug(funcName, "defaultBranch: %v", defaultBranch)
 
 	// If we couldn't find the default branch, return error.
 	if defaultBranch == "" {
-		cmn.Debug("%s: error: end", funcName)
+		cmn.Trace(funcName, "error: end")
 		return "", fmt.Errorf("could not determine default branch from HEAD ref")
 	}
 
 	// Return default branch.
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
 	return defaultBranch, nil
 }
 
 // GetRemoteRefId will retrieve the commit id for a given ref.
-func GetRemoteRefId(url string, ref string) (string, error) {
+func GetRemoteRefId(ctx context.Context, project *cmn.Project, url string, ref string) (string, error) {
 	funcName := "git.GetRemoteRefId"
-	cmn.Debug("%s: begin", funcName)
-
-	// If Debug, set debug for git-module.
-	if cmn.Config.DebugFlag {
-		git.SetOutput(os.Stderr)
-		git.SetPrefix("debug: git-module: ")
-	}
+	cmn.Trace(funcName, "begin")
 
 	// Run ls-remote to grab all head refs.
-	refs, err := git.LsRemote(url, git.LsRemoteOptions{
-		Patterns: []string{
-			"refs/" + ref,
-		},
-	})
+	refs, err := lsRemote(ctx, project, url, "refs/"+ref, false)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
-		return "", fmt.Errorf("could not retrieve HEAD ref from remote: %s", err.Error())
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("%w: could not retrieve HEAD ref from remote: %w", cmn.ErrRemote, err)
 	}
 
+	if len(refs) == 0 {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("no matching ref on remote: refs/%s", ref)
+	}
 	if len(refs) > 1 {
-		cmn.Debug("%s: error: end", funcName)
+		cmn.Trace(funcName, "error: end")
 		return "", fmt.Errorf("got more than one matching ref from remote: %d", len(refs))
 	}
 
-	cmn.Debug("%s: found ref: %#v", funcName, refs[0])
+	cmn.Debug(funcName, "found ref: %#v", refs[0])
 
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
 	return refs[0].ID, nil
 }
 
+// Version will retrieve the version of the git binary. The project may be nil.
+func Version(ctx context.Context, project *cmn.Project) (string, error) {
+	funcName := "git.Version"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"version"}
+
+	output, err := runner(project).Run(ctx, "", args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("could not determine git version: %w", err)
+	}
+
+	// Output is "git version 2.39.5", or "git version 2.39.5.windows.1".
+	fields := strings.Fields(string(output))
+	if len(fields) < 3 {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("could not determine git version: %s", strings.TrimSpace(string(output)))
+	}
+	version, _, _ := strings.Cut(fields[2], ".windows")
+	cmn.Debug(funcName, "version: %s", version)
+
+	cmn.Trace(funcName, "end")
+	return version, nil
+}
+
+// Diff will retrieve the uncommitted changes of a worktree.
+func Diff(ctx context.Context, project *cmn.Project, path string) ([]byte, error) {
+	funcName := "git.Diff"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"diff"}
+	args = append(args, "HEAD")
+
+	output, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not get diff of %s: %w", path, err)
+	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// Log will retrieve the one-line log of the most recent commits of a worktree.
+func Log(ctx context.Context, project *cmn.Project, path string, count int) ([]byte, error) {
+	funcName := "git.Log"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"log"}
+	args = append(args, "--oneline", "--decorate", fmt.Sprintf("--max-count=%d", count))
+
+	output, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not get log of %s: %w", path, err)
+	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// Merge will merge a ref into the branch checked out in a worktree,
+// optionally only if it fast-forwards.
+func Merge(ctx context.Context, project *cmn.Project, path string, ref string, ffOnly bool) ([]byte, error) {
+	funcName := "git.Merge"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"merge"}
+	if ffOnly {
+		args = append(args, "--ff-only")
+	} else {
+		args = append(args, "--no-edit")
+	}
+	args = append(args, ref)
+
+	output, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return output, err
+	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// MergeAbort will abort a merge in progress in a worktree.
+func MergeAbort(ctx context.Context, project *cmn.Project, path string) error {
+	funcName := "git.MergeAbort"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"merge"}
+	args = append(args, "--abort")
+
+	_, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not abort merge in %s: %w", path, err)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// Rebase will rebase the branch checked out in a worktree onto a ref.
+func Rebase(ctx context.Context, project *cmn.Project, path string, onto string) ([]byte, error) {
+	funcName := "git.Rebase"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"rebase"}
+	args = append(args, onto)
+
+	output, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return output, err
+	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// RebaseAbort will abort a rebase in progress in a worktree.
+func RebaseAbort(ctx context.Context, project *cmn.Project, path string) error {
+	funcName := "git.RebaseAbort"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"rebase"}
+	args = append(args, "--abort")
+
+	_, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not abort rebase in %s: %w", path, err)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// Head will return the commit id checked out in a worktree.
+func Head(ctx context.Context, project *cmn.Project, path string) (string, error) {
+	funcName := "git.Head"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"rev-parse"}
+	args = append(args, "--verify", "HEAD")
+
+	output, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("could not resolve HEAD in %s: %w", path, err)
+	}
+	id := strings.TrimSpace(string(output))
+	cmn.Debug(funcName, "%s: %s", path, id)
+
+	cmn.Trace(funcName, "end")
+	return id, nil
+}
+
+// InProgress will return the operation git stopped in the middle of in a
+// worktree, "rebase" or "merge", or an empty string if there is none.
+func InProgress(ctx context.Context, project *cmn.Project, path string) (string, error) {
+	funcName := "git.InProgress"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"rev-parse"}
+	args = append(args, "--path-format=absolute", "--git-path", "rebase-merge", "--git-path", "rebase-apply", "--git-path", "MERGE_HEAD")
+
+	output, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("could not read the state of %s: %w", path, err)
+	}
+	paths := strings.Split(strings.TrimSpace(string(output)), "\n")
+	operations := []string{"rebase", "rebase", "merge"}
+	for i, v := range paths {
+		if _, err := os.Stat(v); err == nil && i < len(operations) {
+			cmn.Debug(funcName, "%s in progress: %s", operations[i], v)
+			cmn.Trace(funcName, "end")
+			return operations[i], nil
+		}
+	}
+
+	cmn.Trace(funcName, "end")
+	return "", nil
+}
+
+// Status will retrieve the porcelain status lines of a worktree.
+func Status(ctx context.Context, project *cmn.Project, path string) ([]string, error) {
+	funcName := "git.Status"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"status"}
+	args = append(args, "--porcelain")
+
+	output, err := runner(project).Run(ctx, path, args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not get status of %s: %w", path, err)
+	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	lines := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	cmn.Trace(funcName, "end")
+	return lines, nil
+}
+
 // WorktreeAdd will add a worktree to the project.
-func WorktreeAdd(config *cmn.CfgMk, worktree string, commitish string) ([]byte, error) {
+func WorktreeAdd(ctx context.Context, project *cmn.Project, config *cmn.CfgMk, worktree string, commitish string) ([]byte, error) {
 	funcName := "git.WorktreeAdd"
-	cmn.Debug("%s: begin", funcName)
-	cmn.Debug("%s: config: %#v", funcName, config)
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "config: %#v", config)
 
-	cmd := git.NewCommand("worktree")
-	cmd.AddArgs("add")
+	args := []string{"worktree"}
+	args = append(args, "add")
 
 	if config.Quiet {
-		cmd.AddArgs("--quiet")
+		args = append(args, "--quiet")
 	}
 	if config.Force {
-		cmd.AddArgs("--force")
+		args = append(args, "--force")
 	}
 	if config.CheckoutNo {
-		cmd.AddArgs("--no-checkout")
+		args = append(args, "--no-checkout")
 	}
 	if config.Track {
-		cmd.AddArgs("--track")
+		args = append(args, "--track")
+	}
+	if config.Lock {
+		args = append(args, "--lock")
+		if len(config.LockReason) > 0 {
+			args = append(args, "--reason", config.LockReason)
+		}
 	}
 	if len(config.Branch) > 0 {
-		cmd.AddArgs("-b", config.Branch)
+		args = append(args, "-b", config.Branch)
 	}
 	if len(config.BranchReset) > 0 {
-		cmd.AddArgs("-B", config.BranchReset)
+		args = append(args, "-B", config.BranchReset)
 	}
 
-	cmd.AddArgs(filepath.Join(cmn.Config.ProjectDir, worktree))
+	args = append(args, filepath.Join(project.ProjectDir, worktree))
 	if len(config.RefId) > 0 {
-		cmd.AddArgs(config.RefId)
+		args = append(args, config.RefId)
 	} else {
-		cmd.AddArgs(commitish)
+		args = append(args, commitish)
 	}
 
-	cmn.Debug("%s: command: %s", funcName, cmd.String())
-
-	runDir := ""
-	if cmn.Config.InitialDir == cmn.Config.ProjectDir {
-		runDir = filepath.Join(cmn.Config.InitialDir, cmn.Config.DefaultBranch)
-	} else {
-		runDir = cmn.Config.InitialDir
-	}
-	output, err := cmd.RunInDir(runDir)
+	output, err := runner(project).Run(ctx, runDir(project), args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
+		cmn.Trace(funcName, "error: end")
 		return nil, err
 	}
-	cmn.Debug("%s: output length: %d", funcName, len(output))
+	cmn.Debug(funcName, "output length: %d", len(output))
 
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
 	return output, nil
 }
 
 // WorktreeList will list all worktrees in the project.
-func WorktreeList(porcelain bool) ([]byte, error) {
+func WorktreeList(ctx context.Context, project *cmn.Project, porcelain bool) ([]byte, error) {
 	funcName := "git.WorktreeList"
-	cmn.Debug("%s: begin", funcName)
+	cmn.Trace(funcName, "begin")
 
-	cmd := git.NewCommand("worktree")
-	cmd.AddArgs("list")
+	args := []string{"worktree"}
+	args = append(args, "list")
 
 	if porcelain {
-		cmd.AddArgs("--porcelain")
+		args = append(args, "--porcelain")
+	} else {
+		args = append(args, "--verbose")
+	}
+
+	output, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
 	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
 
-	cmn.Debug("%s: command: %s", funcName, cmd.String())
+// GetWorktrees will retrieve and parse the worktrees of the project.
+func GetWorktrees(ctx context.Context, project *cmn.Project) ([]Worktree, error) {
+	funcName := "git.GetWorktrees"
+	cmn.Trace(funcName, "begin")
 
-	runDir := ""
-	if cmn.Config.InitialDir == cmn.Config.ProjectDir {
-		runDir = filepath.Join(cmn.Config.InitialDir, cmn.Config.DefaultBranch)
-	} else {
-		runDir = cmn.Config.InitialDir
+	output, err := WorktreeList(ctx, project, true)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("error listing worktrees: %w", err)
+	}
+
+	worktrees := []Worktree{}
+	var current *Worktree
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		cmn.Debug(funcName, "output line: %s", line)
+
+		key, value, _ := strings.Cut(line, " ")
+		if key == "worktree" {
+			worktrees = append(worktrees, Worktree{Path: filepath.Clean(value)})
+			current = &worktrees[len(worktrees)-1]
+			if rel, err := filepath.Rel(project.ProjectDir, current.Path); err == nil {
+				current.Name = rel
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch key {
+		case "HEAD":
+			current.Head = value
+		case "branch":
+			current.Branch = value
+		case "bare":
+			current.Bare = true
+		case "detached":
+			current.Detached = true
+		case "locked":
+			current.Locked = true
+			current.LockReason = value
+		case "prunable":
+			current.Prunable = true
+		case "":
+			current = nil
+		}
+	}
+	cmn.Debug(funcName, "worktrees: %#v", worktrees)
+
+	cmn.Trace(funcName, "end")
+	return worktrees, nil
+}
+
+// GetWorktree will retrieve a worktree of the project by name.
+func GetWorktree(ctx context.Context, project *cmn.Project, name string) (Worktree, error) {
+	funcName := "git.GetWorktree"
+	cmn.Trace(funcName, "begin")
+
+	worktrees, err := GetWorktrees(ctx, project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
 	}
-	output, err := cmd.RunInDir(runDir)
+
+	for _, v := range worktrees {
+		if v.Name == filepath.Clean(name) {
+			cmn.Debug(funcName, "found worktree: %#v", v)
+			cmn.Trace(funcName, "end")
+			return v, nil
+		}
+	}
+
+	cmn.Trace(funcName, "error: end")
+	return Worktree{}, fmt.Errorf("%w named %s in project", cmn.ErrNoWorktree, name)
+}
+
+// CurrentWorktree will retrieve the worktree containing the initial directory.
+func CurrentWorktree(ctx context.Context, project *cmn.Project) (Worktree, error) {
+	funcName := "git.CurrentWorktree"
+	cmn.Trace(funcName, "begin")
+
+	worktrees, err := GetWorktrees(ctx, project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+
+	current := Worktree{}
+	for _, v := range worktrees {
+		if v.Contains(project.InitialDir) && len(v.Path) > len(current.Path) {
+			current = v
+		}
+	}
+
+	if current.Path == "" {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("not in a worktree: %s", project.InitialDir)
+	}
+	cmn.Debug(funcName, "current worktree: %#v", current)
+
+	cmn.Trace(funcName, "end")
+	return current, nil
+}
+
+// FindWorktree will find a worktree of the project from a query, trying in
+// turn an exact name, an exact branch, a name prefix, a substring and finally a
+// fuzzy subsequence match. A query matching more than one worktree at the
+// first matching step is an error.
+func FindWorktree(ctx context.Context, project *cmn.Project, query string) (Worktree, error) {
+	funcName := "git.FindWorktree"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "query: %s", query)
+
+	worktrees, err := GetWorktrees(ctx, project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+
+	lower := strings.ToLower(filepath.ToSlash(query))
+	matchers := []func(Worktree) bool{
+		func(w Worktree) bool { return filepath.ToSlash(w.Name) == filepath.ToSlash(query) },
+		func(w Worktree) bool { return w.BranchName() == query },
+		func(w Worktree) bool {
+			return strings.HasPrefix(strings.ToLower(filepath.ToSlash(w.Name)), lower) ||
+				strings.HasPrefix(strings.ToLower(filepath.Base(w.Name)), lower)
+		},
+		func(w Worktree) bool { return strings.Contains(strings.ToLower(filepath.ToSlash(w.Name)), lower) },
+		func(w Worktree) bool { return FuzzyMatch(filepath.ToSlash(w.Name), query) },
+	}
+
+	for i, match := range matchers {
+		found := []Worktree{}
+		for _, v := range worktrees {
+			if v.InProject() && !v.Bare && match(v) {
+				found = append(found, v)
+			}
+		}
+		cmn.Debug(funcName, "matcher %d found %d worktrees", i, len(found))
+
+		if len(found) == 1 {
+			cmn.Debug(funcName, "found worktree: %#v", found[0])
+			cmn.Trace(funcName, "end")
+			return found[0], nil
+		}
+		if len(found) > 1 {
+			names := []string{}
+			for _, v := range found {
+				names = append(names, v.Name)
+			}
+			cmn.Trace(funcName, "error: end")
+			return Worktree{}, fmt.Errorf("ambiguous worktree %s: matches %s", query, strings.Join(names, ", "))
+		}
+	}
+
+	cmn.Trace(funcName, "error: end")
+	return Worktree{}, fmt.Errorf("%w matching %s in project", cmn.ErrNoWorktree, query)
+}
+
+// FuzzyMatch reports whether the characters of query appear in order in s,
+// ignoring case.
+func FuzzyMatch(s string, query string) bool {
+	target := []rune(strings.ToLower(s))
+	i := 0
+	for _, r := range strings.ToLower(query) {
+		for i < len(target) && target[i] != r {
+			i++
+		}
+		if i == len(target) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// WorktreeLock will lock a worktree of the project.
+func WorktreeLock(ctx context.Context, project *cmn.Project, worktree string, reason string) ([]byte, error) {
+	funcName := "git.WorktreeLock"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"worktree"}
+	args = append(args, "lock")
+
+	if len(reason) > 0 {
+		args = append(args, "--reason", reason)
+	}
+
+	args = append(args, filepath.Join(project.ProjectDir, worktree))
+
+	output, err := runner(project).Run(ctx, runDir(project), args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
+		cmn.Trace(funcName, "error: end")
 		return nil, err
 	}
-	cmn.Debug("%s: output length: %d", funcName, len(output))
+	cmn.Debug(funcName, "output length: %d", len(output))
 
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// WorktreeUnlock will unlock a worktree of the project.
+func WorktreeUnlock(ctx context.Context, project *cmn.Project, worktree string) ([]byte, error) {
+	funcName := "git.WorktreeUnlock"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"worktree"}
+	args = append(args, "unlock", filepath.Join(project.ProjectDir, worktree))
+
+	output, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
 	return output, nil
 }
 
 // WorktreeMove will move a worktree within the project.
-func WorktreeMove(config *cmn.CfgMv, wtOriginal string, wtNew string) ([]byte, error) {
+func WorktreeMove(ctx context.Context, project *cmn.Project, config *cmn.CfgMv, wtOriginal string, wtNew string) ([]byte, error) {
 	funcName := "git.WorktreeMove"
-	cmn.Debug("%s: begin", funcName)
-	cmn.Debug("%s: config: %#v", funcName, config)
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "config: %#v", config)
 
-	cmd := git.NewCommand("worktree")
-	cmd.AddArgs("move")
+	args := []string{"worktree"}
+	args = append(args, "move")
 
 	if config.Force {
-		cmd.AddArgs("--force")
+		args = append(args, "--force")
 	}
 
-	cmd.AddArgs(filepath.Join(cmn.Config.ProjectDir, wtOriginal), filepath.Join(cmn.Config.ProjectDir, wtNew))
-
-	cmn.Debug("%s: command: %s", funcName, cmd.String())
+	args = append(args, filepath.Join(project.ProjectDir, wtOriginal), filepath.Join(project.ProjectDir, wtNew))
 
-	if strings.HasPrefix(cmn.Config.InitialDir, filepath.Join(cmn.Config.ProjectDir, wtOriginal)) {
-		cmn.Debug("%s: error: end", funcName)
-		return nil, fmt.Errorf("cannot move worktree; current working directory within worktree")
+	if isWithin(project.InitialDir, filepath.Join(project.ProjectDir, wtOriginal)) {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("cannot move worktree; %w", cmn.ErrInsideWorktree)
 	}
 
-	runDir := ""
-	if cmn.Config.InitialDir == cmn.Config.ProjectDir {
-		runDir = filepath.Join(cmn.Config.InitialDir, cmn.Config.DefaultBranch)
-	} else {
-		runDir = cmn.Config.InitialDir
+	// Create parent directories for nested worktree names.
+	err := os.MkdirAll(filepath.Dir(filepath.Join(project.ProjectDir, wtNew)), 0755)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not create parent directory: %w", err)
 	}
-	output, err := cmd.RunInDir(runDir)
+
+	output, err := runner(project).Run(ctx, runDir(project), args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
+		cmn.Trace(funcName, "error: end")
 		return nil, err
 	}
-	cmn.Debug("%s: output length: %d", funcName, len(output))
+	cmn.Debug(funcName, "output length: %d", len(output))
 
-	cmn.Debug("%s: end", funcName)
+	pruneEmptyDirs(project, filepath.Join(project.ProjectDir, wtOriginal))
+
+	cmn.Trace(funcName, "end")
 	return output, nil
 }
 
 // WorktreeRemove will remove a worktree from the project.
-func WorktreeRemove(config *cmn.CfgRm, worktree string) ([]byte, error) {
+func WorktreeRemove(ctx context.Context, project *cmn.Project, config *cmn.CfgRm, worktree string) ([]byte, error) {
 	funcName := "git.WorktreeRemove"
-	cmn.Debug("%s: begin", funcName)
-	cmn.Debug("%s: config: %#v", funcName, config)
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "config: %#v", config)
 
-	cmd := git.NewCommand("worktree")
-	cmd.AddArgs("remove")
+	args := []string{"worktree"}
+	args = append(args, "remove")
 
-	if config.Force {
-		cmd.AddArgs("--force")
+	// Forcing twice removes a locked worktree.
+	for i := 0; i < min(config.Force, 2); i++ {
+		args = append(args, "--force")
 	}
 
-	cmd.AddArgs(worktree)
+	args = append(args, filepath.Join(project.ProjectDir, worktree))
 
-	cmn.Debug("%s: command: %s", funcName, cmd.String())
+	if isWithin(project.InitialDir, filepath.Join(project.ProjectDir, worktree)) {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("cannot remove worktree; %w", cmn.ErrInsideWorktree)
+	}
 
-	if strings.HasPrefix(cmn.Config.InitialDir, filepath.Join(cmn.Config.ProjectDir, worktree)) {
-		cmn.Debug("%s: error: end", funcName)
-		return nil, fmt.Errorf("cannot remove worktree; current working directory within worktree")
+	output, err := runner(project).Run(ctx, runDir(project), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
 	}
+	cmn.Debug(funcName, "output length: %d", len(output))
 
-	runDir := ""
-	if cmn.Config.InitialDir == cmn.Config.ProjectDir {
-		runDir = filepath.Join(cmn.Config.InitialDir, cmn.Config.DefaultBranch)
-	} else {
-		runDir = cmn.Config.InitialDir
+	pruneEmptyDirs(project, filepath.Join(project.ProjectDir, worktree))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// WorktreePrune will prune worktrees whose directories are missing.
+func WorktreePrune(ctx context.Context, project *cmn.Project) ([]byte, error) {
+	funcName := "git.WorktreePrune"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"worktree"}
+	args = append(args, "prune", "--verbose")
+
+	output, err := runner(project).Run(ctx, filepath.Join(project.ProjectDir, project.DefaultBranch), args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return output, err
 	}
-	output, err := cmd.RunInDir(runDir)
+	cmn.Debug(funcName, "output length: %d", len(output))
+
+	cmn.Trace(funcName, "end")
+	return output, nil
+}
+
+// WorktreeRepair will repair the administrative links of worktrees at the
+// given paths, and of every worktree the repository knows of.
+func WorktreeRepair(ctx context.Context, project *cmn.Project, paths []string) ([]byte, error) {
+	funcName := "git.WorktreeRepair"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"worktree"}
+	args = append(args, "repair")
+	args = append(args, paths...)
+
+	output, err := runner(project).Run(ctx, filepath.Join(project.ProjectDir, project.DefaultBranch), args...)
 	if err != nil {
-		cmn.Debug("%s: error: end", funcName)
-		return nil, err
+		cmn.Trace(funcName, "error: end")
+		return output, err
 	}
-	cmn.Debug("%s: output length: %d", funcName, len(output))
+	cmn.Debug(funcName, "output length: %d", len(output))
 
-	cmn.Debug("%s: end", funcName)
+	cmn.Trace(funcName, "end")
 	return output, nil
 }
+
+// RepairLinks will repair the links of every linked worktree found in the
+// project directory and of the given worktrees outside it, returning the
+// worktrees whose links were broken and are repaired, and those whose links
+// are still broken.
+func RepairLinks(ctx context.Context, project *cmn.Project, outside []string) ([]string, []string, error) {
+	funcName := "git.RepairLinks"
+	cmn.Trace(funcName, "begin")
+
+	// The default worktree holds the repository; nothing can be repaired without it.
+	defaultPath := filepath.Join(project.ProjectDir, project.DefaultBranch)
+	if _, err := os.Stat(filepath.Join(defaultPath, ".git")); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, nil, fmt.Errorf("default worktree missing: %s; restore it, or clone again with 'xx --all'", defaultPath)
+	}
+
+	dirs, _, err := ScanProject(project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, nil, err
+	}
+	linked := append([]string{}, outside...)
+	for _, v := range dirs {
+		if v != defaultPath {
+			linked = append(linked, v)
+		}
+	}
+	before := []string{}
+	for _, v := range linked {
+		if CheckLinks(v) != nil {
+			before = append(before, v)
+		}
+	}
+	cmn.Debug(funcName, "broken before repair: %v", before)
+
+	output, err := WorktreeRepair(ctx, project, linked)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, nil, fmt.Errorf("could not repair worktrees: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	repaired := []string{}
+	broken := []string{}
+	for _, v := range before {
+		if CheckLinks(v) != nil {
+			broken = append(broken, v)
+		} else {
+			repaired = append(repaired, v)
+		}
+	}
+	cmn.Debug(funcName, "repaired: %v; broken: %v", repaired, broken)
+
+	cmn.Trace(funcName, "end")
+	return repaired, broken, nil
+}
+
+// CheckLinks will verify that a linked worktree's .git file and the
+// repository's record of the worktree point at each other. Main worktrees,
+// whose .git is a directory, are always consistent.
+func CheckLinks(path string) error {
+	funcName := "git.CheckLinks"
+	cmn.Trace(funcName, "begin")
+
+	dotGit := filepath.Join(path, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("no .git in worktree: %s", path)
+	}
+	if info.IsDir() {
+		cmn.Trace(funcName, "main worktree: end")
+		return nil
+	}
+
+	contents, err := os.ReadFile(dotGit)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not read %s: %w", dotGit, err)
+	}
+	gitdir, found := strings.CutPrefix(strings.TrimSpace(string(contents)), "gitdir: ")
+	if !found {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("invalid .git file: %s", dotGit)
+	}
+	if !filepath.IsAbs(gitdir) {
+		gitdir = filepath.Join(path, gitdir)
+	}
+	cmn.Debug(funcName, "gitdir: %s", gitdir)
+
+	back, err := os.ReadFile(filepath.Join(gitdir, "gitdir"))
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf(".git points at a missing repository entry: %s", gitdir)
+	}
+	target := strings.TrimSpace(string(back))
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(gitdir, target)
+	}
+	cmn.Debug(funcName, "gitdir target: %s", target)
+
+	same, err := sameFile(target, dotGit)
+	if err != nil || !same {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("repository entry points elsewhere: %s", target)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// sameFile reports whether two paths name the same existing file.
+func sameFile(a string, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(infoA, infoB), nil
+}
+
+// ScanProject will walk the project directory for worktrees, returning the
+// directories holding a .git, and the stray directories holding no worktree.
+func ScanProject(project *cmn.Project) ([]string, []string, error) {
+	funcName := "git.ScanProject"
+	cmn.Trace(funcName, "begin")
+
+	worktrees := []string{}
+	strays := []string{}
+
+	// scan reports whether dir holds a worktree, collecting worktrees and the
+	// stray directories beneath it.
+	var scan func(dir string) (bool, error)
+	scan = func(dir string) (bool, error) {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			worktrees = append(worktrees, dir)
+			return true, nil
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return false, err
+		}
+		found := false
+		children := []string{}
+		for _, v := range entries {
+			child := filepath.Join(dir, v.Name())
+			if !v.IsDir() || child == project.StateDir() {
+				continue
+			}
+			ok, err := scan(child)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				found = true
+			} else {
+				children = append(children, child)
+			}
+		}
+		// Report the topmost stray directories only.
+		if found || dir == project.ProjectDir {
+			strays = append(strays, children...)
+		}
+		return found, nil
+	}
+
+	_, err := scan(project.ProjectDir)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, nil, fmt.Errorf("could not scan project directory: %w", err)
+	}
+	cmn.Debug(funcName, "worktrees: %v", worktrees)
+	cmn.Debug(funcName, "strays: %v", strays)
+
+	cmn.Trace(funcName, "end")
+	return worktrees, strays, nil
+}
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git/gittest"
+)
+
+// fakeProject returns a project in /project on the default branch 'main'
+// whose git commands are answered by fake.
+func fakeProject(fake *gittest.Fake) *cmn.Project {
+	return &cmn.Project{
+		DefaultBranch: "main",
+		InitialDir:    "/project",
+		ProjectDir:    "/project",
+		Remote:        "origin",
+		Runner:        fake,
+	}
+}
+
+func TestGetWorktrees(t *testing.T) {
+	fake := (&gittest.Fake{}).On("worktree list", `worktree /project/main
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /project/feature/one
+HEAD 2222222222222222222222222222222222222222
+detached
+locked on hold
+
+worktree /elsewhere
+HEAD 3333333333333333333333333333333333333333
+branch refs/heads/elsewhere
+prunable gitdir file points to non-existent location
+
+`, nil)
+
+	worktrees, err := GetWorktrees(context.Background(), fakeProject(fake))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Worktree{
+		{Path: "/project/main", Name: "main", Head: "1111111111111111111111111111111111111111", Branch: "refs/heads/main"},
+		{Path: "/project/feature/one", Name: "feature/one", Head: "2222222222222222222222222222222222222222", Detached: true, Locked: true, LockReason: "on hold"},
+		{Path: "/elsewhere", Name: "../elsewhere", Head: "3333333333333333333333333333333333333333", Branch: "refs/heads/elsewhere", Prunable: true},
+	}
+	if len(worktrees) != len(want) {
+		t.Fatalf("got %d worktrees, want %d: %#v", len(worktrees), len(want), worktrees)
+	}
+	for i := range want {
+		if worktrees[i] != want[i] {
+			t.Errorf("worktree %d: got %#v, want %#v", i, worktrees[i], want[i])
+		}
+	}
+	if worktrees[2].InProject() {
+		t.Errorf("%s: reported within the project", worktrees[2].Path)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 || calls[0].Dir != "/project/main" || strings.Join(calls[0].Args, " ") != "worktree list --porcelain" {
+		t.Errorf("got calls %#v", calls)
+	}
+}
+
+func TestGetRemoteDefaultBranch(t *testing.T) {
+	fake := (&gittest.Fake{}).On("ls-remote", "ref: refs/heads/trunk\tHEAD\n1111111111111111111111111111111111111111\tHEAD\n", nil)
+
+	branch, err := GetRemoteDefaultBranch(context.Background(), fakeProject(fake), "file:///origin.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "trunk" {
+		t.Errorf("got %s, want trunk", branch)
+	}
+	if got := fake.Commands("ls-remote"); len(got) != 1 || got[0] != "ls-remote --quiet --symref file:///origin.git HEAD" {
+		t.Errorf("got commands %q", got)
+	}
+}
+
+func TestGetRemoteRefId(t *testing.T) {
+	fake := (&gittest.Fake{}).On("ls-remote", "", nil)
+
+	if _, err := GetRemoteRefId(context.Background(), fakeProject(fake), "file:///origin.git", "pull/7/head"); err == nil {
+		t.Error("expected a missing ref to fail")
+	}
+
+	fake.On("ls-remote", "2222222222222222222222222222222222222222\trefs/pull/7/head\n", nil)
+	id, err := GetRemoteRefId(context.Background(), fakeProject(fake), "file:///origin.git", "pull/7/head")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "2222222222222222222222222222222222222222" {
+		t.Errorf("got %s", id)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	for output, want := range map[string]string{
+		"git version 2.39.5\n":           "2.39.5",
+		"git version 2.45.1.windows.1\n": "2.45.1",
+	} {
+		fake := (&gittest.Fake{}).On("version", output, nil)
+
+		version, err := Version(context.Background(), fakeProject(fake))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != want {
+			t.Errorf("%q: got %s, want %s", output, version, want)
+		}
+	}
+
+	fake := (&gittest.Fake{}).On("version", "", errors.New("exec: \"git\": executable file not found in $PATH"))
+	if _, err := Version(context.Background(), fakeProject(fake)); err == nil {
+		t.Error("expected a failing git to fail")
+	}
+}
+
+func TestModuleRunCancelled(t *testing.T) {
+	cause := fmt.Errorf("%w after 1s", cmn.ErrTimeout)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cause)
+
+	if _, err := (Module{}).Run(ctx, "", "version"); !errors.Is(err, cmn.ErrTimeout) {
+		t.Errorf("got error %v, want %v", err, cause)
+	}
+}
+
+func TestModuleRunNoDefaultTimeout(t *testing.T) {
+	// A timeout of zero would have git-module kill git after a minute.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	opts := options(ctx)
+	if opts.Timeout >= 0 {
+		t.Errorf("got timeout %s; want none", opts.Timeout)
+	}
+	if opts.Context != ctx {
+		t.Error("context not passed to git-module")
+	}
+}
@@ -0,0 +1,98 @@
+// Package gittest provides a fake git runner for tests. It answers commands
+// from a script instead of running git, and records every invocation.
+package gittest
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+type (
+	Call struct {
+		Dir  string   // Directory git was run in.
+		Args []string // Arguments git was run with.
+	} // Invocation of git recorded by a Fake.
+
+	response struct {
+		prefix []string // Leading arguments of the commands answered.
+		output []byte   // Standard output to return.
+		err    error    // Error to return.
+	} // Scripted answer to matching commands.
+
+	Fake struct {
+		mu        sync.Mutex
+		responses []response
+		calls     []Call
+	} // Runner answering commands from a script; safe for concurrent use.
+)
+
+// On scripts the output and error returned for commands whose arguments start
+// with the words of command, e.g. "worktree list". The latest matching script
+// wins; commands matching none succeed with no output.
+func (f *Fake) On(command string, output string, err error) *Fake {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses = append(f.responses, response{
+		prefix: strings.Fields(command),
+		output: []byte(output),
+		err:    err,
+	})
+	return f
+}
+
+// Run records the invocation and returns the scripted answer, or the cause of
+// ctx without recording anything if ctx is done.
+func (f *Fake) Run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, Call{Dir: dir, Args: append([]string{}, args...)})
+	for i := len(f.responses) - 1; i >= 0; i-- {
+		if hasPrefix(args, f.responses[i].prefix) {
+			return f.responses[i].output, f.responses[i].err
+		}
+	}
+	return nil, nil
+}
+
+// Calls returns the invocations recorded so far, in order.
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Call{}, f.calls...)
+}
+
+// Commands returns the arguments of the invocations recorded so far whose
+// arguments start with the words of prefix, each joined with spaces.
+func (f *Fake) Commands(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	commands := []string{}
+	for _, v := range f.calls {
+		if hasPrefix(v.Args, strings.Fields(prefix)) {
+			commands = append(commands, strings.Join(v.Args, " "))
+		}
+	}
+	return commands
+}
+
+// hasPrefix reports whether args start with prefix.
+func hasPrefix(args []string, prefix []string) bool {
+	if len(prefix) > len(args) {
+		return false
+	}
+	for i, v := range prefix {
+		if args[i] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gogs/git-module"
+	"github.com/jason-dour/git-wt/internal/cmn"
+)
+
+type (
+	Module struct{} // Runner running the git binary through git-module.
+
+	remoteRef struct {
+		ID      string // Commit id, or "ref:" for a symbolic ref.
+		Refspec string // Full name of the ref.
+	} // Ref as reported by 'git ls-remote'.
+)
+
+const (
+	noTimeout = -1 // Timeout telling git-module to set none of its own, instead of its one-minute default.
+)
+
+// Run will run git with args in dir, or the current directory if dir is
+// empty, returning its standard output. Git is not started once ctx is done,
+// and is killed if ctx is done while it runs; the error is then the cause of
+// ctx. Git runs for as long as ctx allows, without a time limit of its own.
+// Each run is logged at the debug level with its arguments, directory,
+// duration and exit code.
+func (Module) Run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	start := time.Now()
+	err := git.NewCommand(args...).
+		AddOptions(options(ctx)).
+		RunInDirPipeline(stdout, stderr, dir)
+	duration := time.Since(start)
+
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else if err != nil {
+		exitCode = -1
+	}
+	cmn.Logger().Debug("git",
+		"argv", append([]string{"git"}, args...),
+		"dir", dir,
+		"duration", duration,
+		"exit", exitCode,
+	)
+
+	if err != nil && ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	if errors.Is(err, git.ErrExecTimeout) {
+		return nil, fmt.Errorf("%w: git %s", cmn.ErrTimeout, strings.Join(args, " "))
+	}
+	if err != nil {
+		if stderr.Len() > 0 {
+			return nil, fmt.Errorf("%w - %s", err, stderr.String())
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
+
+// options returns the git-module options running git under ctx alone.
+func options(ctx context.Context) git.CommandOptions {
+	return git.CommandOptions{Context: ctx, Timeout: noTimeout}
+}
+
+// runner returns the runner of a project; Module if the project is nil or
+// sets none.
+func runner(project *cmn.Project) cmn.Runner {
+	if project == nil || project.Runner == nil {
+		return Module{}
+	}
+	return project.Runner
+}
+
+// lsRemote will list the refs of a remote repository matching a pattern,
+// with symbolic refs shown as such if symref is set.
+func lsRemote(ctx context.Context, project *cmn.Project, url string, pattern string, symref bool) ([]remoteRef, error) {
+	funcName := "git.lsRemote"
+	cmn.Trace(funcName, "begin")
+
+	args := []string{"ls-remote", "--quiet"}
+	if symref {
+		args = append(args, "--symref")
+	}
+	args = append(args, url, pattern)
+
+	output, err := runner(project).Run(ctx, "", args...)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	refs := []remoteRef{}
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		refs = append(refs, remoteRef{ID: fields[0], Refspec: fields[1]})
+	}
+
+	cmn.Trace(funcName, "end")
+	return refs, nil
+}
@@ -0,0 +1,34 @@
+//go:build unix
+
+package git
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+)
+
+func TestModuleRunDeadline(t *testing.T) {
+	// Reading a FIFO without a writer blocks git until it is killed.
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeoutCause(context.Background(), 200*time.Millisecond, cmn.ErrTimeout)
+	defer cancel()
+
+	start := time.Now()
+	_, err := Module{}.Run(ctx, "", "hash-object", fifo)
+
+	if !errors.Is(err, cmn.ErrTimeout) {
+		t.Errorf("got %v, want ErrTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("git ran %s past the deadline", elapsed)
+	}
+}
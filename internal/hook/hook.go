@@ -0,0 +1,111 @@
+// Package hook implements running the hook commands configured for a git-wt
+// project.
+package hook
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+)
+
+type (
+	Env struct {
+		Worktree    string // Name of the worktree relative to the project directory.
+		Path        string // Absolute path of the worktree.
+		Branch      string // Branch checked out in the worktree.
+		Base        string // Commit id the worktree was based on.
+		OldWorktree string // Previous name of the worktree; set for 'post-mv'.
+	} // Environment describing the worktree a hook runs for.
+)
+
+const (
+	PostMk    = "post-mk"    // Runs in a worktree after it is added.
+	PreRm     = "pre-rm"     // Runs in a worktree before it is removed.
+	PostRm    = "post-rm"    // Runs in the project directory after a worktree is removed.
+	PostMv    = "post-mv"    // Runs in a worktree after it is moved.
+	PostClone = "post-clone" // Runs in the default worktree after it is cloned.
+)
+
+var (
+	waitDelay = 2 * time.Second // Wait for the output of a killed hook before giving up on it.
+)
+
+// environ builds the process environment for a hook.
+func environ(project *cmn.Project, name string, env Env) []string {
+	return append(os.Environ(),
+		"GIT_WT_HOOK="+name,
+		"GIT_WT_PROJECT_DIR="+project.ProjectDir,
+		"GIT_WT_DEFAULT_BRANCH="+project.DefaultBranch,
+		"GIT_WT_REMOTE="+project.Remote,
+		"GIT_WT_WORKTREE="+env.Worktree,
+		"GIT_WT_WORKTREE_PATH="+env.Path,
+		"GIT_WT_BRANCH="+env.Branch,
+		"GIT_WT_BASE="+env.Base,
+		"GIT_WT_OLD_WORKTREE="+env.OldWorktree,
+	)
+}
+
+// shell returns the command used to run a hook command line, killed if ctx
+// is done while it runs.
+func shell(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// Run runs the commands configured for a hook in dir, in order, writing
+// their output to w; nil discards it.
+//
+// A failing command returns an error if the project's hook-failure policy is
+// abort; otherwise a warning is written to w and the remaining commands still
+// run. If ctx is done, the running command is killed, the remaining ones are
+// not started, and the cause of ctx is returned whatever the policy.
+func Run(ctx context.Context, project *cmn.Project, name string, dir string, env Env, w io.Writer) error {
+	funcName := "hook.Run"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "hook: %s; dir: %s; env: %#v", name, dir, env)
+
+	if w == nil {
+		w = io.Discard
+	}
+	for _, v := range project.Hooks[name] {
+		if err := context.Cause(ctx); err != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%s hook stopped: %s: %w", name, v, err)
+		}
+		cmn.Debug(funcName, "running %s hook: %s", name, v)
+
+		cmd := shell(ctx, v)
+		cmd.Dir = dir
+		cmd.Env = environ(project, name, env)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = w
+		cmd.Stderr = w
+		cmd.WaitDelay = waitDelay
+
+		err := cmd.Run()
+		if cause := context.Cause(ctx); err != nil && cause != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%s hook stopped: %s: %w", name, v, cause)
+		}
+		if err != nil {
+			if project.HookFailure == cmn.HookFailureWarn {
+				cmn.Debug(funcName, "hook failed; warning")
+				fmt.Fprintf(w, "warning: %s hook failed: %s: %s\n", name, v, err.Error())
+				continue
+			}
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%s hook failed: %s: %w", name, v, err)
+		}
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
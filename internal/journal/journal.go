@@ -0,0 +1,185 @@
+// Package journal implements the journal of a git-wt project, recording what
+// each command changing the project did so the latest change can be undone.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+)
+
+type (
+	Entry struct {
+		ID           int64     `json:"id"`                     // Identifies the entry; increases with each entry.
+		Time         time.Time `json:"time"`                   // When the operation ended.
+		Op           string    `json:"op"`                     // Operation: mk, mv, rm, xx or undo.
+		Command      string    `json:"command"`                // Command line that ran the operation.
+		Undoes       int64     `json:"undoes,omitempty"`       // Entry an undo reversed; zero if the undo failed part way.
+		Irreversible bool      `json:"irreversible,omitempty"` // Whether the operation cannot be undone.
+		Actions      []Action  `json:"actions"`                // Changes made, in order.
+	} // Operation on a project, as recorded in the journal.
+
+	Action struct {
+		Kind        string `json:"kind"`                   // What changed: add, remove, move, delete-branch, create-branch or reset-branch.
+		Worktree    string `json:"worktree,omitempty"`     // Name of the worktree after the change; before it for remove.
+		Branch      string `json:"branch,omitempty"`       // Branch of the worktree after the change, or the deleted branch.
+		Head        string `json:"head,omitempty"`         // Commit id of the worktree or deleted branch.
+		NewBranch   bool   `json:"new_branch,omitempty"`   // Whether add created the branch.
+		OldWorktree string `json:"old_worktree,omitempty"` // Name of the worktree before a move.
+		OldBranch   string `json:"old_branch,omitempty"`   // Branch before a move renamed it.
+		OldHead     string `json:"old_head,omitempty"`     // Commit id of a branch before add or reset-branch reset it.
+		Locked      bool   `json:"locked,omitempty"`       // Whether the worktree was locked.
+		LockReason  string `json:"lock_reason,omitempty"`  // Reason the worktree was locked.
+	} // Change to a worktree or branch, with its state before and after.
+)
+
+const (
+	File = "journal.jsonl" // Name of the journal in the project's state directory.
+
+	OpMk   = "mk"   // Worktree added.
+	OpMv   = "mv"   // Worktree moved.
+	OpRm   = "rm"   // Worktrees removed.
+	OpXx   = "xx"   // Project reset.
+	OpUndo = "undo" // Earlier entry reversed.
+
+	ActionAdd          = "add"           // Worktree added, with its branch if NewBranch.
+	ActionRemove       = "remove"        // Worktree removed; its branch is kept.
+	ActionMove         = "move"          // Worktree moved, with its branch renamed if OldBranch is set.
+	ActionDeleteBranch = "delete-branch" // Branch deleted.
+	ActionCreateBranch = "create-branch" // Branch created again by an undo.
+	ActionResetBranch  = "reset-branch"  // Branch reset from OldHead to Head.
+)
+
+// Path returns the path of the project's journal.
+func Path(project *cmn.Project) string {
+	return filepath.Join(project.StateDir(), File)
+}
+
+// New returns an entry for an operation of the running command.
+func New(op string) *Entry {
+	return &Entry{Op: op, Command: cmn.CommandLine()}
+}
+
+// Add adds a completed action to the entry.
+func (e *Entry) Add(action Action) {
+	e.Actions = append(e.Actions, action)
+}
+
+// Append appends an entry to the project's journal, setting its ID and time.
+// An entry without actions is not appended, unless it is irreversible.
+func Append(project *cmn.Project, entry *Entry) error {
+	funcName := "journal.Append"
+	cmn.Trace(funcName, "begin")
+
+	if len(entry.Actions) == 0 && !entry.Irreversible {
+		cmn.Debug(funcName, "nothing to record")
+		cmn.Trace(funcName, "end")
+		return nil
+	}
+
+	entries, err := Read(project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	entry.ID = 1
+	if len(entries) > 0 {
+		entry.ID = entries[len(entries)-1].ID + 1
+	}
+	entry.Time = time.Now().Truncate(time.Second)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	cmn.Debug(funcName, "entry: %s", line)
+
+	err = os.MkdirAll(project.StateDir(), 0755)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not create state directory: %w", err)
+	}
+	file, err := os.OpenFile(Path(project), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not open journal: %w", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not write journal: %w", err)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// Read reads the entries of the project's journal, oldest first; none if the
+// project has no journal.
+func Read(project *cmn.Project) ([]Entry, error) {
+	funcName := "journal.Read"
+	cmn.Trace(funcName, "begin")
+
+	file, err := os.Open(Path(project))
+	if errors.Is(err, fs.ErrNotExist) {
+		cmn.Trace(funcName, "end")
+		return []Entry{}, nil
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not open journal: %w", err)
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := Entry{}
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return nil, fmt.Errorf("invalid journal entry on line %d of %s: %w", n, Path(project), err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not read journal: %w", err)
+	}
+	cmn.Debug(funcName, "entries: %d", len(entries))
+
+	cmn.Trace(funcName, "end")
+	return entries, nil
+}
+
+// Last returns the latest entry that is neither an undo nor undone. An undo
+// that failed part way reversed nothing as far as Last is concerned, so it is
+// returned to be undone itself.
+func Last(entries []Entry) (Entry, bool) {
+	undone := map[int64]bool{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		v := entries[i]
+		switch {
+		case v.Op == OpUndo && v.Undoes != 0:
+			undone[v.Undoes] = true
+		case !undone[v.ID]:
+			return v, true
+		}
+	}
+	return Entry{}, false
+}
@@ -0,0 +1,334 @@
+// Package pick implements interactively picking a worktree of the project,
+// with a built-in fuzzy finder or an external fzf.
+package pick
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git"
+	"golang.org/x/term"
+)
+
+type (
+	item struct {
+		worktree git.Worktree // Worktree the item stands for.
+		line     string       // Line displayed for the worktree.
+	} // Pickable worktree.
+)
+
+var (
+	ErrCancelled = errors.New("pick cancelled") // Returned when nothing was picked.
+)
+
+const (
+	maxRows = 10 // Maximum number of worktrees shown by the built-in picker.
+)
+
+// Worktree lets the user pick a worktree of the project interactively.
+func Worktree(ctx context.Context, project *cmn.Project, prompt string) (git.Worktree, error) {
+	funcName := "pick.Worktree"
+	cmn.Trace(funcName, "begin")
+
+	items, err := list(ctx, project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return git.Worktree{}, err
+	}
+	if len(items) == 0 {
+		cmn.Trace(funcName, "error: end")
+		return git.Worktree{}, fmt.Errorf("no worktrees to pick from")
+	}
+
+	index := -1
+	if project.Picker == cmn.PickerFzf {
+		index, err = fzf(prompt, items)
+	} else {
+		index, err = builtin(prompt, items)
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return git.Worktree{}, err
+	}
+	cmn.Debug(funcName, "picked: %#v", items[index].worktree)
+
+	cmn.Trace(funcName, "end")
+	return items[index].worktree, nil
+}
+
+// list builds the pickable worktrees with their branch and status.
+func list(ctx context.Context, project *cmn.Project) ([]item, error) {
+	funcName := "pick.list"
+	cmn.Trace(funcName, "begin")
+
+	worktrees, err := git.GetWorktrees(ctx, project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	pickable := []git.Worktree{}
+	nameWidth, branchWidth := 0, 0
+	for _, v := range worktrees {
+		if !v.InProject() || v.Bare {
+			continue
+		}
+		pickable = append(pickable, v)
+		nameWidth = max(nameWidth, utf8.RuneCountInString(v.Name))
+		branchWidth = max(branchWidth, utf8.RuneCountInString(v.BranchLabel()))
+	}
+
+	items := []item{}
+	for _, v := range pickable {
+		status := "clean"
+		lines, err := git.Status(ctx, project, v.Path)
+		if err != nil {
+			status = "unknown"
+		} else if len(lines) > 0 {
+			status = strconv.Itoa(len(lines)) + " changed"
+		}
+		items = append(items, item{
+			worktree: v,
+			line:     fmt.Sprintf("%-*s  %-*s  %s", nameWidth, v.Name, branchWidth, v.BranchLabel(), status),
+		})
+	}
+
+	cmn.Trace(funcName, "end")
+	return items, nil
+}
+
+// Score reports whether the characters of query appear in order in s, ignoring
+// case, and scores the match; consecutive characters, matches at the start of
+// s or of a word, and shorter strings score higher.
+func Score(s string, query string) (int, bool) {
+	target := []rune(strings.ToLower(s))
+	score := 0
+	last := -2
+	i := 0
+	for _, r := range strings.ToLower(query) {
+		for i < len(target) && target[i] != r {
+			i++
+		}
+		if i == len(target) {
+			return 0, false
+		}
+		switch {
+		case i == last+1:
+			score += 3
+		case i == 0 || !unicode.IsLetter(target[i-1]) && !unicode.IsDigit(target[i-1]):
+			score += 2
+		default:
+			score += 1
+		}
+		last = i
+		i++
+	}
+	return score*100 - len(target), true
+}
+
+// filter returns the indexes of the items matching query, best match first.
+func filter(items []item, query string) []int {
+	scores := map[int]int{}
+	matches := []int{}
+	for i, v := range items {
+		if score, ok := Score(v.line, query); ok {
+			scores[i] = score
+			matches = append(matches, i)
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool {
+		return scores[matches[a]] > scores[matches[b]]
+	})
+	return matches
+}
+
+// fzf picks an item with the external fzf, falling back to the built-in picker
+// if fzf is not found.
+func fzf(prompt string, items []item) (int, error) {
+	funcName := "pick.fzf"
+	cmn.Trace(funcName, "begin")
+
+	path, err := exec.LookPath("fzf")
+	if err != nil {
+		cmn.Trace(funcName, "fzf not found; using built-in picker: end")
+		return builtin(prompt, items)
+	}
+
+	input := strings.Builder{}
+	for i, v := range items {
+		fmt.Fprintf(&input, "%d\t%s\n", i, v.line)
+	}
+
+	output := bytes.Buffer{}
+	cmd := exec.Command(path, "--prompt", prompt, "--height", "40%", "--reverse",
+		"--delimiter", "\t", "--with-nth", "2..")
+	cmd.Stdin = strings.NewReader(input.String())
+	cmd.Stdout = &output
+	cmd.Stderr = os.Stderr
+	cmn.Debug(funcName, "command: %s", cmd.String())
+
+	err = cmd.Run()
+	if exitErr := (*exec.ExitError)(nil); errors.As(err, &exitErr) {
+		// fzf exits 1 for no match and 130 when interrupted.
+		cmn.Debug(funcName, "fzf exit code: %d: end", exitErr.ExitCode())
+		return -1, ErrCancelled
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return -1, fmt.Errorf("could not run fzf: %w", err)
+	}
+
+	field, _, _ := strings.Cut(output.String(), "\t")
+	index, err := strconv.Atoi(field)
+	if err != nil || index < 0 || index >= len(items) {
+		cmn.Trace(funcName, "error: end")
+		return -1, fmt.Errorf("unexpected fzf output: %s", output.String())
+	}
+
+	cmn.Trace(funcName, "end")
+	return index, nil
+}
+
+// builtin picks an item with the built-in fuzzy finder, drawn on Stderr and
+// read from Stdin.
+func builtin(prompt string, items []item) (int, error) {
+	funcName := "pick.builtin"
+	cmn.Trace(funcName, "begin")
+
+	in := int(os.Stdin.Fd())
+	if !term.IsTerminal(in) {
+		cmn.Trace(funcName, "error: end")
+		return -1, fmt.Errorf("picking a worktree requires a terminal")
+	}
+
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return -1, fmt.Errorf("could not set terminal mode: %w", err)
+	}
+	defer term.Restore(in, state)
+
+	width, height, err := term.GetSize(int(os.Stderr.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+	rows := min(len(items), maxRows, max(height-1, 1))
+
+	query := []rune{}
+	matches := filter(items, "")
+	selected, offset := 0, 0
+	buf := make([]byte, 64)
+	for {
+		// Keep the selection visible.
+		selected = max(min(selected, len(matches)-1), 0)
+		if selected < offset {
+			offset = selected
+		} else if selected >= offset+rows {
+			offset = selected - rows + 1
+		}
+
+		draw(prompt, query, items, matches, selected, offset, rows, width)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			erase()
+			cmn.Trace(funcName, "error: end")
+			return -1, fmt.Errorf("could not read terminal: %w", err)
+		}
+
+		key := buf[:n]
+		switch {
+		case bytes.Equal(key, []byte("\x1b[A")), bytes.Equal(key, []byte("\x1bOA")):
+			selected--
+		case bytes.Equal(key, []byte("\x1b[B")), bytes.Equal(key, []byte("\x1bOB")):
+			selected++
+		case key[0] == 0x1b && n == 1, key[0] == 0x03, key[0] == 0x04:
+			// Escape, Ctrl-C or Ctrl-D.
+			erase()
+			cmn.Trace(funcName, "cancelled: end")
+			return -1, ErrCancelled
+		case key[0] == '\r', key[0] == '\n':
+			if len(matches) > 0 {
+				erase()
+				cmn.Trace(funcName, "end")
+				return matches[selected], nil
+			}
+		case key[0] == 0x10:
+			// Ctrl-P.
+			selected--
+		case key[0] == 0x0e, key[0] == '\t':
+			// Ctrl-N or Tab.
+			selected++
+		case key[0] == 0x7f, key[0] == 0x08:
+			// Backspace.
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+		case key[0] == 0x15:
+			// Ctrl-U.
+			query = query[:0]
+		case key[0] == 0x17:
+			// Ctrl-W.
+			query = []rune(strings.TrimRightFunc(strings.TrimRightFunc(string(query), unicode.IsSpace), func(r rune) bool {
+				return !unicode.IsSpace(r)
+			}))
+		case key[0] >= 0x20 && utf8.Valid(key):
+			query = append(query, []rune(string(key))...)
+		default:
+			continue
+		}
+
+		matches = filter(items, string(query))
+	}
+}
+
+// draw renders the prompt and the visible matches, leaving the cursor after
+// the query.
+func draw(prompt string, query []rune, items []item, matches []int, selected int, offset int, rows int, width int) {
+	out := os.Stderr
+	fmt.Fprintf(out, "\r\x1b[J%s%s", prompt, string(query))
+	for i := 0; i < rows; i++ {
+		fmt.Fprint(out, "\r\n\x1b[K")
+		if offset+i >= len(matches) {
+			continue
+		}
+		line := truncate(items[matches[offset+i]].line, width-2)
+		if offset+i == selected {
+			fmt.Fprintf(out, "\x1b[7m> %s\x1b[0m", line)
+		} else {
+			fmt.Fprintf(out, "  %s", line)
+		}
+	}
+	if rows > 0 {
+		fmt.Fprintf(out, "\x1b[%dA", rows)
+	}
+	fmt.Fprint(out, "\r")
+	if column := utf8.RuneCountInString(prompt) + len(query); column > 0 {
+		fmt.Fprintf(out, "\x1b[%dC", column)
+	}
+}
+
+// truncate shortens a line to at most width runes, never splitting a
+// multi-byte character.
+func truncate(line string, width int) string {
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:max(width, 0)])
+	}
+	return line
+}
+
+// erase removes the picker from the terminal.
+func erase() {
+	fmt.Fprint(os.Stderr, "\r\x1b[J")
+}
@@ -0,0 +1,26 @@
+package pick
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{line: "feature", width: 10, want: "feature"},
+		{line: "feature", width: 4, want: "feat"},
+		{line: "fix/ünïcödé", width: 6, want: "fix/ün"},
+		{line: "日本語ブランチ", width: 3, want: "日本語"},
+		{line: "feature", width: -1, want: ""},
+	}
+	for _, tt := range tests {
+		got := truncate(tt.line, tt.width)
+		if got != tt.want || !utf8.ValidString(got) {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,578 @@
+// Package ui implements a full-screen terminal dashboard for a git-wt project,
+// listing worktrees with live status and acting on them with keystrokes.
+package ui
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/hook"
+	"github.com/jason-dour/git-wt/pkg/wt"
+	"golang.org/x/term"
+)
+
+type (
+	row struct {
+		worktree wt.Worktree // Worktree shown on the row.
+		status   string      // Status of the worktree.
+	} // Row of the worktree list.
+
+	screen struct {
+		ctx      context.Context // Context of the dashboard; cancels its git commands.
+		project  *wt.Project     // Project shown on the dashboard.
+		tty      *os.File        // Terminal the dashboard is drawn on.
+		state    *term.State     // Terminal state to restore on exit.
+		rows     []row           // Worktrees of the project.
+		selected int             // Index of the selected row.
+		offset   int             // Index of the first visible row.
+		log      bool            // Whether the preview shows the log instead of the diff.
+		preview  []string        // Preview lines of the selected worktree.
+		message  string          // Message shown in the footer.
+		refresh  time.Duration   // Interval between status refreshes.
+	} // State of the dashboard.
+)
+
+const (
+	help    = "enter:cd  c:create  r:rename  d:remove  l:lock  o:open  tab:diff/log  q:quit" // Footer key help.
+	logSize = 200                                                                            // Maximum commits in the log preview.
+)
+
+var (
+	errQuit = errors.New("quit") // Returned by key handlers to leave the dashboard.
+)
+
+// Run shows the dashboard until the user quits, returning the worktree chosen
+// with Enter, if any.
+func Run(ctx context.Context, project *wt.Project) (wt.Worktree, bool, error) {
+	funcName := "ui.Run"
+	cmn.Trace(funcName, "begin")
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return wt.Worktree{}, false, fmt.Errorf("ui requires a terminal: %w", err)
+	}
+	defer tty.Close()
+
+	s := &screen{ctx: ctx, project: project, tty: tty, refresh: 2 * time.Second}
+	err = s.start()
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return wt.Worktree{}, false, err
+	}
+	defer s.stop()
+
+	s.load()
+	chosen := -1
+	buf := make([]byte, 64)
+	for {
+		s.draw()
+
+		// Wait for a key, refreshing statuses when idle.
+		tty.SetReadDeadline(time.Now().Add(s.refresh))
+		n, err := tty.Read(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			s.load()
+			continue
+		}
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return wt.Worktree{}, false, fmt.Errorf("could not read terminal: %w", err)
+		}
+
+		err = s.key(string(buf[:n]))
+		if err == errQuit {
+			break
+		}
+		if err != nil {
+			s.message = "error: " + strings.ReplaceAll(err.Error(), "\n", " ")
+		}
+		if string(buf[:n]) == "\r" && len(s.rows) > 0 {
+			chosen = s.selected
+			break
+		}
+	}
+
+	cmn.Trace(funcName, "end")
+	if chosen < 0 {
+		return wt.Worktree{}, false, nil
+	}
+	return s.rows[chosen].worktree, true, nil
+}
+
+// start switches the terminal to raw mode and the alternate screen.
+func (s *screen) start() error {
+	state, err := term.MakeRaw(int(s.tty.Fd()))
+	if err != nil {
+		return fmt.Errorf("could not set terminal mode: %w", err)
+	}
+	s.state = state
+	fmt.Fprint(s.tty, "\x1b[?1049h\x1b[?25l")
+	return nil
+}
+
+// stop restores the terminal.
+func (s *screen) stop() {
+	fmt.Fprint(s.tty, "\x1b[?25h\x1b[?1049l")
+	term.Restore(int(s.tty.Fd()), s.state)
+}
+
+// size returns the terminal width and height.
+func (s *screen) size() (int, int) {
+	width, height, err := term.GetSize(int(s.tty.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
+// load reloads the worktrees, their statuses and the preview.
+func (s *screen) load() {
+	funcName := "ui.load"
+	cmn.Trace(funcName, "begin")
+
+	worktrees, err := s.project.List(s.ctx)
+	if err != nil {
+		s.message = "error: " + err.Error()
+		cmn.Trace(funcName, "error: end")
+		return
+	}
+
+	// Keep the selection on the same worktree.
+	current := ""
+	if s.selected < len(s.rows) {
+		current = s.rows[s.selected].worktree.Path
+	}
+
+	s.rows = []row{}
+	for _, v := range worktrees {
+		if !v.InProject() || v.Bare {
+			continue
+		}
+		status := "clean"
+		lines, err := s.project.Changes(s.ctx, v)
+		if err != nil {
+			status = "unknown"
+		} else if len(lines) > 0 {
+			status = strconv.Itoa(len(lines)) + " changed"
+		}
+		if v.Path == current {
+			s.selected = len(s.rows)
+		}
+		s.rows = append(s.rows, row{worktree: v, status: status})
+	}
+	s.selected = max(min(s.selected, len(s.rows)-1), 0)
+	s.loadPreview()
+
+	cmn.Trace(funcName, "end")
+}
+
+// loadPreview loads the diff or log of the selected worktree.
+func (s *screen) loadPreview() {
+	s.preview = nil
+	if len(s.rows) == 0 {
+		return
+	}
+
+	selected := s.rows[s.selected].worktree
+	var output []byte
+	var err error
+	if s.log {
+		output, err = s.project.Log(s.ctx, selected, logSize)
+	} else {
+		output, err = s.project.Diff(s.ctx, selected)
+	}
+	if err != nil {
+		s.preview = []string{err.Error()}
+		return
+	}
+	if len(output) == 0 {
+		s.preview = []string{"no changes"}
+		return
+	}
+	s.preview = strings.Split(strings.TrimRight(string(output), "\n"), "\n")
+}
+
+// fit expands tabs, drops control characters and pads or truncates a line to
+// width.
+func fit(line string, width int) string {
+	line = strings.ReplaceAll(line, "\t", "    ")
+	line = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, line)
+	runes := []rune(line)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return line + strings.Repeat(" ", width-len(runes))
+}
+
+// draw renders the whole dashboard.
+func (s *screen) draw() {
+	width, height := s.size()
+	listHeight := max((height-3)/2, 1)
+	previewHeight := max(height-3-listHeight, 0)
+
+	// Keep the selection visible.
+	if s.selected < s.offset {
+		s.offset = s.selected
+	} else if s.selected >= s.offset+listHeight {
+		s.offset = s.selected - listHeight + 1
+	}
+
+	nameWidth, branchWidth := 4, 6
+	for _, v := range s.rows {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(v.worktree.Name))
+		branchWidth = max(branchWidth, utf8.RuneCountInString(v.worktree.BranchLabel()))
+	}
+
+	out := strings.Builder{}
+	out.WriteString("\x1b[H")
+	out.WriteString("\x1b[7m" + fit(" "+cmn.Basename+" ui: "+s.project.Dir, width) + "\x1b[0m")
+
+	for i := 0; i < listHeight; i++ {
+		out.WriteString("\r\n")
+		index := s.offset + i
+		if index >= len(s.rows) {
+			out.WriteString(fit("", width))
+			continue
+		}
+		v := s.rows[index]
+		lock := " "
+		if v.worktree.Locked {
+			lock = "L"
+		}
+		line := fmt.Sprintf(" %s %-*s  %-*s  %s", lock, nameWidth, v.worktree.Name, branchWidth, v.worktree.BranchLabel(), v.status)
+		if index == s.selected {
+			out.WriteString("\x1b[7m" + fit(line, width) + "\x1b[0m")
+		} else {
+			out.WriteString(fit(line, width))
+		}
+	}
+
+	title := " diff "
+	if s.log {
+		title = " log "
+	}
+	if len(s.rows) > 0 {
+		title += s.rows[s.selected].worktree.Name + " "
+	}
+	out.WriteString("\r\n" + fit("──"+title+strings.Repeat("─", max(width-utf8.RuneCountInString(title)-2, 0)), width))
+
+	for i := 0; i < previewHeight; i++ {
+		out.WriteString("\r\n")
+		if i < len(s.preview) {
+			out.WriteString(fit(s.preview[i], width))
+		} else {
+			out.WriteString(fit("", width))
+		}
+	}
+
+	footer := help
+	if s.message != "" {
+		footer = s.message
+	}
+	out.WriteString("\r\n\x1b[7m" + fit(footer, width) + "\x1b[0m")
+
+	s.tty.WriteString(out.String())
+}
+
+// prompt reads a line of input in the footer, returning false if cancelled.
+func (s *screen) prompt(label string, value string) (string, bool) {
+	width, height := s.size()
+	input := []rune(value)
+	buf := make([]byte, 64)
+	for {
+		line := fit(label+string(input), width-1)
+		fmt.Fprintf(s.tty, "\x1b[%d;1H\x1b[0m%s\x1b[%d;%dH\x1b[?25h", height, line, height, min(utf8.RuneCountInString(label)+len(input)+1, width))
+
+		s.tty.SetReadDeadline(time.Time{})
+		n, err := s.tty.Read(buf)
+		fmt.Fprint(s.tty, "\x1b[?25l")
+		if err != nil {
+			return "", false
+		}
+
+		key := string(buf[:n])
+		if key == "\x1b" {
+			return "", false
+		}
+		if strings.HasPrefix(key, "\x1b") {
+			// Ignore cursor and function keys.
+			continue
+		}
+		for _, r := range key {
+			switch {
+			case r == '\r', r == '\n':
+				return strings.TrimSpace(string(input)), true
+			case r == 0x03:
+				return "", false
+			case r == 0x7f, r == 0x08:
+				if len(input) > 0 {
+					input = input[:len(input)-1]
+				}
+			case r == 0x15:
+				input = input[:0]
+			case !unicode.IsControl(r) && r != utf8.RuneError:
+				input = append(input, r)
+			}
+		}
+	}
+}
+
+// suspend restores the terminal while fn runs, then waits for Enter if wait
+// is set, and takes the screen back.
+func (s *screen) suspend(wait bool, fn func() error) error {
+	s.stop()
+	fmt.Fprint(s.tty, "\x1b[2J\x1b[H")
+	err := fn()
+	if err != nil {
+		fmt.Fprintf(s.tty, "error: %s\n", err.Error())
+	}
+	if wait || err != nil {
+		fmt.Fprint(s.tty, "\nPress Enter to return.")
+		buf := make([]byte, 64)
+		s.tty.SetReadDeadline(time.Time{})
+		s.tty.Read(buf)
+	}
+	s.start()
+	fmt.Fprint(s.tty, "\x1b[2J")
+	return err
+}
+
+// act runs an operation of package wt, capturing its output. If any of the
+// hooks the operation may run has commands, the terminal is restored while it
+// runs, so the hooks can use it, and the output is shown afterwards.
+func (s *screen) act(hooks []string, fn func(w io.Writer) error) error {
+	output := &bytes.Buffer{}
+	if !s.project.HasHooks(hooks...) {
+		return fn(output)
+	}
+	return s.suspend(true, func() error {
+		err := fn(output)
+		s.tty.Write(output.Bytes())
+		return err
+	})
+}
+
+// key handles a keystroke.
+func (s *screen) key(key string) error {
+	s.message = ""
+	switch key {
+	case "q", "\x1b", "\x03":
+		return errQuit
+	case "\x1b[A", "\x1bOA", "k":
+		s.selected = max(s.selected-1, 0)
+		s.loadPreview()
+	case "\x1b[B", "\x1bOB", "j":
+		s.selected = max(min(s.selected+1, len(s.rows)-1), 0)
+		s.loadPreview()
+	case "\t":
+		s.log = !s.log
+		s.loadPreview()
+	case "R":
+		s.load()
+	case "c":
+		return s.create()
+	case "r":
+		return s.rename()
+	case "d":
+		return s.remove()
+	case "l":
+		return s.lock()
+	case "o":
+		return s.open()
+	}
+	return nil
+}
+
+// create adds a worktree on a new branch from the default branch, or checks
+// out a commit-ish if one is given.
+func (s *screen) create() error {
+	input, ok := s.prompt("create (name [commit-ish]): ", "")
+	if !ok || input == "" {
+		return nil
+	}
+
+	name, opts, err := addOptions(s.project, input)
+	if err != nil {
+		return err
+	}
+
+	var added wt.Worktree
+	err = s.act([]string{hook.PostMk}, func(w io.Writer) error {
+		opts.Out = w
+		var err error
+		added, err = s.project.Add(s.ctx, name, opts)
+		return err
+	})
+	s.load()
+	if added.Path != "" {
+		s.focus(added.Path)
+	}
+	if err == nil {
+		s.message = "created " + added.Name
+	}
+	return err
+}
+
+// addOptions splits the input of create, 'name [commit-ish]', into the name of
+// the worktree and the options adding it: a commit-ish is checked out as given,
+// and otherwise a branch named after the worktree is created from the default
+// branch.
+func addOptions(project *wt.Project, input string) (string, wt.AddOptions, error) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", wt.AddOptions{}, fmt.Errorf("%w: give a name and an optional commit-ish", wt.ErrUsage)
+	}
+	opts := wt.AddOptions{Commitish: project.DefaultBranch, Quiet: true}
+	if len(fields) > 1 {
+		opts.Commitish = fields[1]
+	} else {
+		name, err := project.WorktreeName(fields[0])
+		if err != nil {
+			return "", wt.AddOptions{}, err
+		}
+		opts.Branch = filepath.ToSlash(name)
+	}
+	return fields[0], opts, nil
+}
+
+// rename moves the selected worktree, renaming its branch too if the project
+// asks for it.
+func (s *screen) rename() error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+	selected := s.rows[s.selected].worktree
+	input, ok := s.prompt("rename "+selected.Name+" to: ", selected.Name)
+	if !ok || input == "" || input == selected.Name {
+		return nil
+	}
+
+	var moved wt.Worktree
+	err := s.act([]string{hook.PostMv}, func(w io.Writer) error {
+		var err error
+		moved, err = s.project.Move(s.ctx, selected.Name, input, wt.MoveOptions{Branch: s.project.MvBranch, Out: w})
+		return err
+	})
+	s.load()
+	if moved.Path != "" {
+		s.focus(moved.Path)
+	}
+	if err == nil {
+		s.message = "renamed " + selected.Name + " to " + moved.Name
+	}
+	return err
+}
+
+// remove removes the selected worktree after confirmation.
+func (s *screen) remove() error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+	selected := s.rows[s.selected].worktree
+	input, ok := s.prompt("remove "+selected.Name+"? (y/n/force): ", "")
+	if !ok || (input != "y" && input != "force") {
+		return nil
+	}
+
+	opts := wt.RemoveOptions{}
+	if input == "force" {
+		opts.Force = 1
+	}
+	err := s.act([]string{hook.PreRm, hook.PostRm}, func(w io.Writer) error {
+		opts.Out = w
+		return s.project.Remove(s.ctx, selected.Name, opts)
+	})
+	s.load()
+	if err == nil {
+		s.message = "removed " + selected.Name
+	}
+	return err
+}
+
+// lock locks the selected worktree with a reason, or unlocks it.
+func (s *screen) lock() error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+	selected := s.rows[s.selected].worktree
+
+	// Ask for the reason first, so the project is not locked while typing.
+	reason := ""
+	if !selected.Locked {
+		input, ok := s.prompt("lock "+selected.Name+" reason: ", "")
+		if !ok {
+			return nil
+		}
+		reason = input
+	}
+
+	if selected.Locked {
+		_, err := s.project.Unlock(s.ctx, selected.Name)
+		if err != nil {
+			return err
+		}
+		s.message = "unlocked " + selected.Name
+	} else {
+		_, err := s.project.Lock(s.ctx, selected.Name, reason)
+		if err != nil {
+			return err
+		}
+		s.message = "locked " + selected.Name
+	}
+	s.load()
+	return nil
+}
+
+// open opens the selected worktree in the user's editor.
+func (s *screen) open() error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+	selected := s.rows[s.selected].worktree
+
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	return s.suspend(false, func() error {
+		cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", selected.Path)
+		cmd.Dir = selected.Path
+		cmd.Stdin = s.tty
+		cmd.Stdout = s.tty
+		cmd.Stderr = s.tty
+		return cmd.Run()
+	})
+}
+
+// focus moves the selection to the worktree at path.
+func (s *screen) focus(path string) {
+	for i, v := range s.rows {
+		if v.worktree.Path == path {
+			s.selected = i
+			s.loadPreview()
+			return
+		}
+	}
+}
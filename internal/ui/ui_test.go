@@ -0,0 +1,90 @@
+package ui
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/pkg/wt"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		width int
+		want  string
+	}{
+		{name: "pad", line: "abc", width: 5, want: "abc  "},
+		{name: "exact", line: "abcde", width: 5, want: "abcde"},
+		{name: "truncate", line: "abcdefg", width: 5, want: "abcde"},
+		{name: "empty", line: "", width: 3, want: "   "},
+		{name: "zero width", line: "abc", width: 0, want: ""},
+		{name: "tab", line: "a\tb", width: 8, want: "a    b  "},
+		{name: "tab truncated", line: "a\tb", width: 3, want: "a  "},
+		{name: "control characters", line: "a\x1b[31mb\rc\x00", width: 8, want: "a[31mbc "},
+		{name: "runes truncated", line: "héllo wörld", width: 7, want: "héllo w"},
+		{name: "runes padded", line: "日本", width: 4, want: "日本  "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fit(tt.line, tt.width)
+
+			if got != tt.want {
+				t.Errorf("fit(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddOptions(t *testing.T) {
+	dir := t.TempDir()
+	config := "default: trunk\nslashes: flatten\n"
+	if err := os.WriteFile(filepath.Join(dir, "."+cmn.Basename), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project, err := wt.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		input     string
+		wantName  string
+		wantOpts  wt.AddOptions
+		wantError error // Error expected; nil if the input is valid.
+	}{
+		{name: "new branch", input: "feature", wantName: "feature",
+			wantOpts: wt.AddOptions{Commitish: "trunk", Branch: "feature", Quiet: true}},
+		{name: "new branch flattened", input: "feature/one", wantName: "feature/one",
+			wantOpts: wt.AddOptions{Commitish: "trunk", Branch: "feature-one", Quiet: true}},
+		{name: "surrounding whitespace", input: "  feature  ", wantName: "feature",
+			wantOpts: wt.AddOptions{Commitish: "trunk", Branch: "feature", Quiet: true}},
+		{name: "commit-ish", input: "review origin/dev", wantName: "review",
+			wantOpts: wt.AddOptions{Commitish: "origin/dev", Quiet: true}},
+		{name: "empty", input: " ", wantError: wt.ErrUsage},
+		{name: "too many fields", input: "a b c", wantError: wt.ErrUsage},
+		{name: "invalid name", input: "-f", wantError: cmn.ErrInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, opts, err := addOptions(project, tt.input)
+
+			if tt.wantError != nil {
+				if !errors.Is(err, tt.wantError) {
+					t.Errorf("addOptions(%q) error = %v, want %v", tt.input, err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("addOptions(%q): %v", tt.input, err)
+			}
+			if name != tt.wantName || opts != tt.wantOpts {
+				t.Errorf("addOptions(%q) = %q, %#v; want %q, %#v", tt.input, name, opts, tt.wantName, tt.wantOpts)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package wt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git"
+)
+
+type (
+	DefaultOptions struct {
+		Out io.Writer // Receives progress messages and warnings; nil discards them.
+	} // Options for Project.SetDefault.
+
+	reversal struct {
+		description string       // What reversing the change does.
+		undo        func() error // Reverses the change.
+	} // Change made by SetDefault, with how to reverse it.
+)
+
+// SetDefault switches the project to the default branch of the remote, e.g.
+// after it moved from master to main: it renames the local default branch and
+// the default worktree to match, updates the config file, tracks the new
+// branch on the remote, and fast-forwards it if the worktree is clean. If a
+// step fails, the changes made are reversed.
+//
+// If the directory the project was opened from moved along with the default
+// worktree, its new path is returned, even with an error for worktrees whose
+// links could not be repaired; otherwise the path is empty.
+func (p *Project) SetDefault(ctx context.Context, opts DefaultOptions) (string, error) {
+	funcName := "wt.SetDefault"
+	cmn.Trace(funcName, "begin")
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	defer unlock()
+
+	w := out(opts.Out)
+
+	// Detect the default branch of the remote.
+	oldBranch := p.project.DefaultBranch
+	oldPath := filepath.Join(p.project.ProjectDir, oldBranch)
+	url, err := git.GetRemote(ctx, p.project, oldPath)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("error determining remote: %w", err)
+	}
+	newBranch, err := git.GetRemoteDefaultBranch(ctx, p.project, url)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	cmn.Debug(funcName, "remote default branch: %s", newBranch)
+	if newBranch == oldBranch {
+		fmt.Fprintf(w, "Default branch is already %s.\n", oldBranch)
+		cmn.Trace(funcName, "end")
+		return "", nil
+	}
+
+	err = p.checkDefault(ctx, newBranch)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+
+	// Fetch the new branch from the remote.
+	_, err = git.Fetch(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	upstream := p.project.Remote + "/" + newBranch
+	if !git.RefExists(ctx, p.project, "refs/remotes/"+upstream) {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("%s not found after fetching", upstream)
+	}
+
+	// Only mirrors the remote, so it is refreshed before anything changes.
+	err = git.SetRemoteHead(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+
+	// Worktrees outside the project link into the repository too.
+	worktrees, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	outside := []string{}
+	for _, v := range worktrees {
+		if _, err := os.Stat(v.Path); err == nil && !v.InProject() && !v.Bare {
+			outside = append(outside, v.Path)
+		}
+	}
+
+	// Each change records how to reverse it, so a failure leaves the project
+	// as it was; reversals run on even if the call is interrupted.
+	changes := []reversal{}
+	undoCtx := context.WithoutCancel(ctx)
+
+	// Rename the branch.
+	err = git.RenameBranch(ctx, p.project, oldBranch, newBranch)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	fmt.Fprintf(w, "Renamed branch: %s -> %s\n", oldBranch, newBranch)
+	changes = append(changes, reversal{
+		description: "rename branch " + newBranch + " back to " + oldBranch,
+		undo:        func() error { return git.RenameBranch(undoCtx, p.project, newBranch, oldBranch) },
+	})
+
+	// Rename the default worktree; as the main worktree, git cannot move it.
+	newPath := filepath.Join(p.project.ProjectDir, newBranch)
+	err = os.MkdirAll(filepath.Dir(newPath), 0755)
+	if err == nil {
+		err = os.Rename(oldPath, newPath)
+	}
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", reverse(w, changes, fmt.Errorf("could not move default worktree to %s: %w", newPath, err))
+	}
+	fmt.Fprintf(w, "Moved default worktree: %s -> %s\n", oldBranch, newBranch)
+
+	initialDir := p.project.InitialDir
+	p.DefaultBranch, p.project.DefaultBranch = newBranch, newBranch
+	cwd := p.follow(oldPath, newPath)
+	changes = append(changes, reversal{
+		description: "move default worktree " + newBranch + " back to " + oldBranch,
+		undo: func() error {
+			if err := os.Rename(newPath, oldPath); err != nil {
+				return err
+			}
+			p.DefaultBranch, p.project.DefaultBranch = oldBranch, oldBranch
+			p.project.InitialDir = initialDir
+			_, _, err := git.RepairLinks(undoCtx, p.project, outside)
+			return err
+		},
+	})
+
+	// Repair the links of the other worktrees into the moved repository.
+	_, broken, err := git.RepairLinks(ctx, p.project, outside)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", reverse(w, changes, fmt.Errorf("error repairing worktrees: %w", err))
+	}
+	for _, v := range broken {
+		fmt.Fprintf(w, "Could not repair worktree: %s\n", v)
+	}
+
+	// Update the config.
+	err = p.project.UpdateConfig(func(key string, value string) string {
+		if key == "default" {
+			return newBranch
+		}
+		return value
+	})
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", reverse(w, changes, err)
+	}
+	changes = append(changes, reversal{
+		description: "restore the default branch in the ." + cmn.Basename + " file",
+		undo: func() error {
+			return p.project.UpdateConfig(func(key string, value string) string {
+				if key == "default" {
+					return oldBranch
+				}
+				return value
+			})
+		},
+	})
+
+	// Track the new branch on the remote; the last change, so it need not be
+	// reversed.
+	err = git.SetUpstream(ctx, p.project, newBranch, upstream)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", reverse(w, changes, err)
+	}
+
+	// Fast-forward the branch if the worktree is clean.
+	lines, err := git.Status(ctx, p.project, newPath)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return cwd, err
+	}
+	if len(lines) > 0 {
+		fmt.Fprintf(w, "Default worktree has uncommitted changes; not updated from %s\n", upstream)
+	} else if _, err := git.Merge(ctx, p.project, newPath, upstream, true); err != nil {
+		fmt.Fprintf(w, "Default worktree diverged from %s; not updated\n", upstream)
+	}
+
+	if len(broken) > 0 {
+		cmn.Trace(funcName, "error: end")
+		return cwd, fmt.Errorf("could not repair %d worktrees; run 'repair'", len(broken))
+	}
+
+	cmn.Trace(funcName, "end")
+	return cwd, nil
+}
+
+// checkDefault checks that the project can switch to the new default branch.
+func (p *Project) checkDefault(ctx context.Context, newBranch string) error {
+	funcName := "wt.checkDefault"
+	cmn.Trace(funcName, "begin")
+
+	newPath := filepath.Join(p.project.ProjectDir, newBranch)
+	if _, err := os.Lstat(newPath); err == nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%s %w; move or remove it first", newPath, cmn.ErrExists)
+	}
+	if git.RefExists(ctx, p.project, git.RefsHeads+newBranch) {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("local branch %s %w; rename or delete it first", newBranch, cmn.ErrExists)
+	}
+
+	found, err := git.GetWorktree(ctx, p.project, p.project.DefaultBranch)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	if found.BranchName() != p.project.DefaultBranch {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("default worktree not on branch %s", p.project.DefaultBranch)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// reverse reverses the changes made, latest first, reporting each to w, and
+// returns err extended with the outcome.
+func reverse(w io.Writer, changes []reversal, err error) error {
+	funcName := "wt.reverse"
+	cmn.Trace(funcName, "begin")
+
+	for i := len(changes) - 1; i >= 0; i-- {
+		undoErr := changes[i].undo()
+		if undoErr != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w; rolled back %d of %d changes, but could not %s: %v", err, len(changes)-1-i, len(changes), changes[i].description, undoErr)
+		}
+		fmt.Fprintf(w, "Rolled back: %s\n", changes[i].description)
+	}
+
+	cmn.Trace(funcName, "end")
+	return fmt.Errorf("%w; rolled back all %d changes", err, len(changes))
+}
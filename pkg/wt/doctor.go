@@ -0,0 +1,148 @@
+package wt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git"
+)
+
+type (
+	Finding struct {
+		Level  string // Severity of the finding: LevelOk, LevelWarn or LevelFail.
+		Check  string // Check that made the finding, e.g. "links".
+		Detail string // Description of the finding.
+	} // Result of a check of Doctor or CheckGit.
+)
+
+const (
+	LevelOk   = "ok"   // Check passed.
+	LevelWarn = "warn" // Check found something worth a look.
+	LevelFail = "fail" // Check found a problem.
+
+	minVersion = "2.36" // Oldest git version supporting every command used.
+)
+
+// CheckGit checks that git runs and is recent enough for the package.
+func CheckGit(ctx context.Context) Finding {
+	version, err := git.Version(ctx, nil)
+	if err != nil {
+		return Finding{LevelFail, "git", err.Error()}
+	}
+	if !atLeast(version, minVersion) {
+		return Finding{LevelWarn, "git", "version " + version + " is older than " + minVersion}
+	}
+	return Finding{LevelOk, "git", "version " + version}
+}
+
+// Doctor checks the project for problems: the config file, the default
+// worktree and the remote, that git's worktree list matches the project
+// directory, that every worktree links back to the repository, and for stray
+// directories. Problems with worktree links can usually be fixed with Repair.
+func (p *Project) Doctor(ctx context.Context) []Finding {
+	funcName := "wt.Doctor"
+	cmn.Trace(funcName, "begin")
+
+	findings := []Finding{{LevelOk, "config", filepath.Join(p.project.ProjectDir, "."+cmn.Basename)}}
+	found, ok := p.checkDefaultWorktree(ctx)
+	findings = append(findings, found...)
+	if ok {
+		findings = append(findings, p.checkWorktrees(ctx)...)
+	}
+
+	cmn.Trace(funcName, "end")
+	return findings
+}
+
+// atLeast reports whether a dotted version is at least the minimum version.
+func atLeast(version string, minimum string) bool {
+	have := strings.Split(version, ".")
+	want := strings.Split(minimum, ".")
+	for i := range want {
+		if i >= len(have) {
+			return false
+		}
+		h, _ := strconv.Atoi(strings.TrimFunc(have[i], func(r rune) bool { return r < '0' || r > '9' }))
+		w, _ := strconv.Atoi(want[i])
+		if h != w {
+			return h > w
+		}
+	}
+	return true
+}
+
+// checkDefaultWorktree checks the default worktree and the remote, reporting
+// whether the default worktree is usable.
+func (p *Project) checkDefaultWorktree(ctx context.Context) ([]Finding, bool) {
+	path := filepath.Join(p.project.ProjectDir, p.project.DefaultBranch)
+	if _, err := os.Stat(path); err != nil {
+		return []Finding{{LevelFail, "default", "worktree missing: " + path}}, false
+	}
+	if err := git.CheckLinks(path); err != nil {
+		return []Finding{{LevelFail, "default", err.Error()}}, false
+	}
+
+	findings := []Finding{{LevelOk, "default", path}}
+	url, err := git.GetRemote(ctx, p.project, path)
+	if err != nil || url == "" {
+		findings = append(findings, Finding{LevelFail, "remote", "remote " + p.project.Remote + " not configured in the default worktree"})
+	} else {
+		findings = append(findings, Finding{LevelOk, "remote", p.project.Remote + ": " + url})
+	}
+	return findings, true
+}
+
+// checkWorktrees checks git's worktree list against the project directory.
+func (p *Project) checkWorktrees(ctx context.Context) []Finding {
+	findings := []Finding{}
+
+	worktrees, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		return []Finding{{LevelFail, "worktrees", "could not list worktrees: " + err.Error()}}
+	}
+	listed := map[string]git.Worktree{}
+	for _, v := range worktrees {
+		if v.Bare {
+			continue
+		}
+		listed[v.Path] = v
+		if _, err := os.Stat(v.Path); err != nil {
+			if v.Locked {
+				findings = append(findings, Finding{LevelWarn, "worktrees", "locked worktree missing: " + v.Path})
+			} else {
+				findings = append(findings, Finding{LevelFail, "worktrees", "worktree missing: " + v.Path + "; run 'repair', or 'repair --prune' if it is gone"})
+			}
+			continue
+		}
+		if v.Name == p.project.DefaultBranch && v.BranchName() != p.project.DefaultBranch {
+			findings = append(findings, Finding{LevelWarn, "worktrees", "default worktree not on branch " + p.project.DefaultBranch})
+		}
+	}
+
+	dirs, strays, err := git.ScanProject(p.project)
+	if err != nil {
+		return append(findings, Finding{LevelFail, "worktrees", err.Error()})
+	}
+	for _, v := range dirs {
+		name, _ := filepath.Rel(p.project.ProjectDir, v)
+		if err := git.CheckLinks(v); err != nil {
+			findings = append(findings, Finding{LevelFail, "links", name + ": " + err.Error() + "; run 'repair'"})
+		} else if _, ok := listed[v]; !ok {
+			findings = append(findings, Finding{LevelFail, "links", name + ": not in git's worktree list; run 'repair'"})
+		}
+	}
+	for _, v := range strays {
+		name, _ := filepath.Rel(p.project.ProjectDir, v)
+		findings = append(findings, Finding{LevelWarn, "strays", "directory holds no worktree: " + name})
+	}
+
+	if len(findings) == 0 {
+		findings = append(findings, Finding{LevelOk, "worktrees", fmt.Sprintf("%d worktrees consistent", len(dirs))})
+	}
+	return findings
+}
@@ -0,0 +1,151 @@
+package wt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git"
+)
+
+type (
+	RelocateOptions struct {
+		Out io.Writer // Receives progress messages and warnings; nil discards them.
+	} // Options for Project.Relocate.
+)
+
+// Relocate moves the project directory to path, which must not exist, repairs
+// the links between every worktree and the repository, and updates paths into
+// the project in the config file. Worktrees outside the project stay put.
+//
+// If the directory the project was opened from moved along, its new path is
+// returned, even with an error for worktrees whose links could not be
+// repaired; otherwise the path is empty.
+func (p *Project) Relocate(ctx context.Context, path string, opts RelocateOptions) (string, error) {
+	funcName := "wt.Relocate"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "path: %s; options: %#v", path, opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	defer unlock()
+
+	w := out(opts.Out)
+
+	oldDir := p.project.ProjectDir
+	newDir, err := p.checkRelocate(path)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	cmn.Debug(funcName, "new project dir: %s", newDir)
+
+	// Worktrees outside the project stay put, but link into the repository.
+	worktrees, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	outside := []string{}
+	for _, v := range worktrees {
+		if v.InProject() || v.Bare {
+			continue
+		}
+		if _, err := os.Stat(v.Path); err != nil {
+			fmt.Fprintf(w, "Warning: worktree outside project is missing; run 'git worktree repair' in it when back: %s\n", v.Path)
+			continue
+		}
+		outside = append(outside, v.Path)
+	}
+
+	// Move the project directory.
+	err = os.Rename(oldDir, newDir)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("could not move project; across filesystems, copy it and run 'repair' instead: %w", err)
+	}
+	fmt.Fprintf(w, "Moved project: %s -> %s\n", oldDir, newDir)
+
+	p.Dir, p.project.ProjectDir = newDir, newDir
+	cwd := p.follow(oldDir, newDir)
+
+	// Repair the links of every worktree.
+	repaired, broken, err := git.RepairLinks(ctx, p.project, outside)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return cwd, fmt.Errorf("error repairing worktrees; run 'repair' in the new location: %w", err)
+	}
+	for _, v := range broken {
+		fmt.Fprintf(w, "Could not repair worktree: %s\n", v)
+	}
+	fmt.Fprintf(w, "Repaired %d worktrees.\n", len(repaired))
+
+	// Update paths into the project in the config file.
+	pattern := regexp.MustCompile(regexp.QuoteMeta(oldDir) + `(/|[\s"':;]|$)`)
+	err = p.project.UpdateConfig(func(key string, value string) string {
+		return pattern.ReplaceAllString(value, strings.ReplaceAll(newDir, "$", "$$")+"${1}")
+	})
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return cwd, err
+	}
+
+	if len(broken) > 0 {
+		cmn.Trace(funcName, "error: end")
+		return cwd, fmt.Errorf("could not repair %d worktrees", len(broken))
+	}
+
+	cmn.Trace(funcName, "end")
+	return cwd, nil
+}
+
+// checkRelocate resolves and checks the new project directory.
+func (p *Project) checkRelocate(path string) (string, error) {
+	funcName := "wt.checkRelocate"
+	cmn.Trace(funcName, "begin")
+
+	newDir, err := filepath.Abs(path)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	if newDir == p.project.ProjectDir {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("project already at %s", newDir)
+	}
+	if rel, err := filepath.Rel(p.project.ProjectDir, newDir); err == nil && filepath.IsLocal(rel) {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("cannot move project into itself: %s", newDir)
+	}
+	if _, err := os.Lstat(newDir); err == nil {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("destination %w: %s", cmn.ErrExists, newDir)
+	}
+	if info, err := os.Stat(filepath.Dir(newDir)); err != nil || !info.IsDir() {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("destination parent is not a directory: %s", filepath.Dir(newDir))
+	}
+
+	cmn.Trace(funcName, "end")
+	return newDir, nil
+}
+
+// follow updates the directory the project was opened from after oldDir was
+// moved to newDir, returning its new path, or an empty string if it was not
+// within oldDir.
+func (p *Project) follow(oldDir string, newDir string) string {
+	rel, err := filepath.Rel(oldDir, p.project.InitialDir)
+	if err != nil || (rel != "." && !filepath.IsLocal(rel)) {
+		return ""
+	}
+	p.project.InitialDir = filepath.Join(newDir, rel)
+	return p.project.InitialDir
+}
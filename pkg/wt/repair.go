@@ -0,0 +1,90 @@
+package wt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git"
+)
+
+type (
+	RepairOptions struct {
+		Prune bool      // Whether to prune worktrees whose directories are gone; locked worktrees are kept.
+		Out   io.Writer // Receives progress messages; nil discards them.
+	} // Options for Project.Repair.
+)
+
+// Repair fixes the links between the repository and every worktree found in
+// the project directory, e.g. after the project was moved or restored, and
+// reports worktrees whose directories are gone, pruning them if asked to.
+func (p *Project) Repair(ctx context.Context, opts RepairOptions) error {
+	funcName := "wt.Repair"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "options: %#v", opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	defer unlock()
+
+	w := out(opts.Out)
+
+	// Repair the links of every worktree, reporting those that were broken.
+	repaired, broken, err := git.RepairLinks(ctx, p.project, nil)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	for _, v := range repaired {
+		name, _ := filepath.Rel(p.project.ProjectDir, v)
+		fmt.Fprintf(w, "Repaired worktree: %s\n", name)
+	}
+	for _, v := range broken {
+		name, _ := filepath.Rel(p.project.ProjectDir, v)
+		fmt.Fprintf(w, "Could not repair worktree: %s\n", name)
+	}
+
+	// Prune or report worktrees whose directories are gone.
+	worktrees, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	missing := []string{}
+	for _, v := range worktrees {
+		if _, err := os.Stat(v.Path); err != nil && !v.Bare && !v.Locked {
+			missing = append(missing, v.Path)
+		}
+	}
+	if opts.Prune && len(missing) > 0 {
+		_, err := git.WorktreePrune(ctx, p.project)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("error pruning worktrees: %w", err)
+		}
+	}
+	for _, v := range missing {
+		if opts.Prune {
+			fmt.Fprintf(w, "Pruned missing worktree: %s\n", v)
+		} else {
+			fmt.Fprintf(w, "Missing worktree: %s; prune it with '%s repair --prune'\n", v, cmn.Basename)
+		}
+	}
+
+	if len(broken) > 0 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not repair %d worktrees", len(broken))
+	}
+	if len(repaired) == 0 {
+		fmt.Fprintln(w, "No broken worktree links found.")
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
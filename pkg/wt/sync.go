@@ -0,0 +1,254 @@
+package wt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git"
+)
+
+type (
+	SyncOptions struct {
+		Rebase bool      // Whether to rebase the other worktrees onto the default branch.
+		Merge  bool      // Whether to merge the default branch into the other worktrees.
+		FfOnly bool      // Whether to fast-forward the other worktrees to the default branch.
+		Out    io.Writer // Receives progress messages; nil discards them.
+	} // Options for Project.Sync.
+
+	SyncResult struct {
+		Worktree Worktree // Worktree that was synced.
+		Status   string   // Outcome, e.g. "rebased", "up to date" or "conflict; rebase aborted".
+		Failed   bool     // Whether the worktree could not be updated.
+	} // Result of syncing a worktree.
+)
+
+// Sync fetches the remote once and fast-forwards the default worktree to the
+// remote default branch. With one of Rebase, Merge or FfOnly the other
+// worktrees are then updated onto it. Worktrees with uncommitted changes are
+// skipped, and a rebase or merge that stops on a conflict is aborted.
+//
+// The result of every worktree visited is returned, even with an error for
+// the worktrees that could not be updated or an interruption between them.
+func (p *Project) Sync(ctx context.Context, opts SyncOptions) ([]SyncResult, error) {
+	funcName := "wt.Sync"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "options: %#v", opts)
+
+	strategies := 0
+	for _, v := range []bool{opts.Rebase, opts.Merge, opts.FfOnly} {
+		if v {
+			strategies++
+		}
+	}
+	if strategies > 1 {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("%w: use one of rebase, merge or ff-only", cmn.ErrUsage)
+	}
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	defer unlock()
+
+	// Fetch the remote once for all worktrees.
+	fmt.Fprintf(out(opts.Out), "Fetching %s...\n", p.project.Remote)
+	_, err = git.Fetch(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	upstream := p.project.Remote + "/" + p.project.DefaultBranch
+
+	worktrees, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	// Update the default worktree first, then the others, stopping between
+	// worktrees if interrupted.
+	todo := []git.Worktree{}
+	for _, v := range worktrees {
+		if v.InProject() && !v.Bare && v.Name == p.project.DefaultBranch {
+			todo = append(todo, v)
+		}
+	}
+	if strategies > 0 {
+		for _, v := range worktrees {
+			if v.InProject() && !v.Bare && v.Name != p.project.DefaultBranch {
+				todo = append(todo, v)
+			}
+		}
+	}
+	results := []SyncResult{}
+	for _, v := range todo {
+		if context.Cause(ctx) != nil {
+			break
+		}
+		if v.Name == p.project.DefaultBranch {
+			results = append(results, p.syncDefault(ctx, v, upstream))
+		} else {
+			results = append(results, p.syncOther(ctx, opts, v, upstream))
+		}
+	}
+
+	failed := 0
+	for _, v := range results {
+		if v.Failed {
+			failed++
+		}
+	}
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return results, fmt.Errorf("stopped after updating %d of %d worktrees: %w", len(results), len(todo), err)
+	}
+	if failed > 0 {
+		cmn.Trace(funcName, "error: end")
+		return results, fmt.Errorf("could not update %d of %d worktrees", failed, len(results))
+	}
+
+	cmn.Trace(funcName, "end")
+	return results, nil
+}
+
+// syncDefault fast-forwards the default worktree to the remote default branch.
+func (p *Project) syncDefault(ctx context.Context, w git.Worktree, upstream string) SyncResult {
+	funcName := "wt.syncDefault"
+	cmn.Trace(funcName, "begin")
+
+	res := SyncResult{Worktree: worktree(w)}
+	if w.BranchName() != p.project.DefaultBranch {
+		res.Status = "skipped: not on " + p.project.DefaultBranch
+		cmn.Debug(funcName, "%s: end", res.Status)
+		return res
+	}
+
+	before, ok := p.prepareSync(ctx, &res)
+	if !ok {
+		cmn.Debug(funcName, "%s: end", res.Status)
+		return res
+	}
+
+	_, err := git.Merge(ctx, p.project, w.Path, upstream, true)
+	if err != nil {
+		cmn.Debug(funcName, "merge failed: %s", err.Error())
+		res.Status, res.Failed = "not fast-forward", true
+		cmn.Debug(funcName, "%s: end", res.Status)
+		return res
+	}
+	p.settleSync(ctx, &res, before, "fast-forwarded")
+
+	cmn.Trace(funcName, "end")
+	return res
+}
+
+// syncOther updates a worktree onto the remote default branch with the
+// strategy of the options, aborting a rebase or merge that stops on a
+// conflict.
+func (p *Project) syncOther(ctx context.Context, opts SyncOptions, w git.Worktree, upstream string) SyncResult {
+	funcName := "wt.syncOther"
+	cmn.Trace(funcName, "begin")
+
+	res := SyncResult{Worktree: worktree(w)}
+	if w.Detached || w.Branch == "" {
+		res.Status = "skipped: detached"
+		cmn.Debug(funcName, "%s: end", res.Status)
+		return res
+	}
+
+	before, ok := p.prepareSync(ctx, &res)
+	if !ok {
+		cmn.Debug(funcName, "%s: end", res.Status)
+		return res
+	}
+
+	var err error
+	switch {
+	case opts.Rebase:
+		_, err = git.Rebase(ctx, p.project, w.Path, upstream)
+		if err != nil {
+			cmn.Debug(funcName, "rebase failed: %s", err.Error())
+			res.Status, res.Failed = p.stoppedSync(ctx, w.Path, err), true
+			break
+		}
+		p.settleSync(ctx, &res, before, "rebased")
+	case opts.Merge:
+		_, err = git.Merge(ctx, p.project, w.Path, upstream, false)
+		if err != nil {
+			cmn.Debug(funcName, "merge failed: %s", err.Error())
+			res.Status, res.Failed = p.stoppedSync(ctx, w.Path, err), true
+			break
+		}
+		p.settleSync(ctx, &res, before, "merged")
+	case opts.FfOnly:
+		_, err = git.Merge(ctx, p.project, w.Path, upstream, true)
+		if err != nil {
+			cmn.Debug(funcName, "merge failed: %s", err.Error())
+			res.Status, res.Failed = "not fast-forward", true
+			break
+		}
+		p.settleSync(ctx, &res, before, "fast-forwarded")
+	}
+	cmn.Debug(funcName, "result: %#v", res)
+
+	cmn.Trace(funcName, "end")
+	return res
+}
+
+// prepareSync checks that a worktree can be updated, returning the commit it
+// has checked out. If it cannot, the reason is set on res and false returned.
+func (p *Project) prepareSync(ctx context.Context, res *SyncResult) (string, bool) {
+	lines, err := git.Status(ctx, p.project, res.Worktree.Path)
+	if err != nil {
+		res.Status, res.Failed = "error: "+err.Error(), true
+		return "", false
+	}
+	if len(lines) > 0 {
+		res.Status = "skipped: uncommitted changes"
+		return "", false
+	}
+	head, err := git.Head(ctx, p.project, res.Worktree.Path)
+	if err != nil {
+		res.Status, res.Failed = "error: "+err.Error(), true
+		return "", false
+	}
+	return head, true
+}
+
+// settleSync sets the status of an updated worktree: done if the update moved
+// its HEAD from before, or up to date if not.
+func (p *Project) settleSync(ctx context.Context, res *SyncResult, before string, done string) {
+	res.Status = done
+	after, err := git.Head(ctx, p.project, res.Worktree.Path)
+	if err == nil && after == before {
+		res.Status = "up to date"
+	}
+}
+
+// stoppedSync returns the status of a worktree whose rebase or merge failed.
+// If git stopped in the middle of it on a conflict, it is aborted; otherwise
+// nothing was changed, and the failure is reported as it is.
+func (p *Project) stoppedSync(ctx context.Context, path string, err error) string {
+	operation, stateErr := git.InProgress(context.WithoutCancel(ctx), p.project, path)
+	if stateErr != nil {
+		return "error: " + stateErr.Error()
+	}
+	if operation == "" {
+		reason, _, _ := strings.Cut(strings.TrimSpace(err.Error()), "\n")
+		return "failed: " + reason
+	}
+
+	abort := git.RebaseAbort
+	if operation == "merge" {
+		abort = git.MergeAbort
+	}
+	if err := abort(context.WithoutCancel(ctx), p.project, path); err != nil {
+		return "conflict; " + operation + " left in progress: " + err.Error()
+	}
+	return "conflict; " + operation + " aborted"
+}
@@ -0,0 +1,427 @@
+package wt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/files"
+	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/internal/journal"
+)
+
+type (
+	UndoOptions struct {
+		Force  int       // Number of times undoing is forced; see Project.Undo.
+		DryRun bool      // Whether to only report what undoing would do.
+		Out    io.Writer // Receives progress messages and warnings; nil discards them.
+	} // Options for Project.Undo.
+)
+
+// Undo reverses the latest operation recorded in the project's journal that
+// is not undone yet: worktrees added are removed, deleting the branches they
+// created and resetting the branches they reset; worktrees removed are added
+// again on their branch, or at their recorded commit if the branch is gone;
+// worktrees moved are moved back; and branches deleted are created again at
+// their recorded commit. Undoing again reverses the operation before.
+//
+// Every step is checked before anything changes. Undoing refuses to lose work
+// unless forced: removing a worktree with uncommitted changes, or deleting a
+// branch with commits made since, needs forcing; a worktree locked since needs
+// forcing twice, and a locked worktree is not moved back. Uncommitted changes
+// of removed worktrees cannot be restored, and hooks do not run. Resetting
+// everything with ResetOptions.All cannot be undone.
+func (p *Project) Undo(ctx context.Context, opts UndoOptions) error {
+	funcName := "wt.Undo"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "options: %#v", opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	defer unlock()
+
+	w := out(opts.Out)
+
+	entries, err := journal.Read(p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	last, ok := journal.Last(entries)
+	if !ok {
+		cmn.Trace(funcName, "error: end")
+		return errors.New("nothing to undo")
+	}
+	cmn.Debug(funcName, "undoing entry: %#v", last)
+	when := last.Time.Local().Format(time.DateTime)
+	if last.Irreversible {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("cannot undo '%s' of %s; what it deleted is gone", last.Command, when)
+	}
+
+	// Check every step, latest first, before changing anything.
+	steps := slices.Clone(last.Actions)
+	slices.Reverse(steps)
+	for _, v := range steps {
+		err := p.checkUndo(ctx, v, opts.Force)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("cannot undo '%s' of %s: %w", last.Command, when, err)
+		}
+	}
+
+	if opts.DryRun {
+		fmt.Fprintf(w, "Would undo '%s' of %s:\n", last.Command, when)
+		for _, v := range steps {
+			fmt.Fprintf(w, "  %s\n", describeUndo(v))
+		}
+		cmn.Trace(funcName, "end")
+		return nil
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	fmt.Fprintf(w, "Undoing '%s' of %s.\n", last.Command, when)
+	entry := journal.New(journal.OpUndo)
+	entry.Undoes = last.ID
+	defer p.record(entry, opts.Out)
+	for i, v := range steps {
+		err := p.undo(ctx, entry, v, opts.Force)
+		if err != nil {
+			// Steps the rollback cannot reverse are recorded as an entry of
+			// their own, undone before the entry this undo failed on.
+			entry.Undoes = 0
+			cmn.Trace(funcName, "error: end")
+			return p.rollback(ctx, entry, w, fmt.Errorf("could not undo step %d of %d of '%s', %s: %w", i+1, len(steps), last.Command, describeUndo(v), err))
+		}
+		fmt.Fprintf(w, "Undone: %s\n", describeUndo(v))
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// describeUndo describes the step reversing an action.
+func describeUndo(a journal.Action) string {
+	switch a.Kind {
+	case journal.ActionAdd:
+		switch {
+		case a.NewBranch:
+			return fmt.Sprintf("remove worktree %s and delete branch %s", a.Worktree, a.Branch)
+		case a.OldHead != "":
+			return fmt.Sprintf("remove worktree %s and reset branch %s to %s", a.Worktree, a.Branch, short(a.OldHead))
+		}
+		return fmt.Sprintf("remove worktree %s", a.Worktree)
+	case journal.ActionRemove:
+		if a.Branch != "" {
+			return fmt.Sprintf("add worktree %s on branch %s", a.Worktree, a.Branch)
+		}
+		return fmt.Sprintf("add worktree %s at %s", a.Worktree, short(a.Head))
+	case journal.ActionMove:
+		if a.OldBranch != "" {
+			return fmt.Sprintf("move worktree %s back to %s and rename branch %s back to %s", a.Worktree, a.OldWorktree, a.Branch, a.OldBranch)
+		}
+		return fmt.Sprintf("move worktree %s back to %s", a.Worktree, a.OldWorktree)
+	case journal.ActionDeleteBranch:
+		return fmt.Sprintf("create branch %s at %s", a.Branch, short(a.Head))
+	case journal.ActionCreateBranch:
+		return fmt.Sprintf("delete branch %s", a.Branch)
+	case journal.ActionResetBranch:
+		return fmt.Sprintf("reset branch %s to %s", a.Branch, short(a.OldHead))
+	}
+	return "reverse " + a.Kind
+}
+
+// short abbreviates a commit id.
+func short(id string) string {
+	return id[:min(len(id), 7)]
+}
+
+// checkUndo checks that an action can be reversed.
+func (p *Project) checkUndo(ctx context.Context, a journal.Action, force int) error {
+	funcName := "wt.checkUndo"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "action: %#v", a)
+
+	switch a.Kind {
+	case journal.ActionAdd:
+		wt, err := git.GetWorktree(ctx, p.project, a.Worktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		if wt.Locked && !a.Locked && force < 2 {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w: %s; force twice to remove it", cmn.ErrWorktreeLocked, a.Worktree)
+		}
+		if force < 1 {
+			lines, err := git.Status(ctx, p.project, wt.Path)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return err
+			}
+			if len(lines) > 0 {
+				cmn.Trace(funcName, "error: end")
+				return fmt.Errorf("%w: %s; force to remove it", cmn.ErrWorktreeDirty, a.Worktree)
+			}
+			if a.NewBranch && a.Branch != "" {
+				head, err := git.RevParse(ctx, p.project, git.RefsHeads+a.Branch)
+				if err == nil && head != a.Head {
+					cmn.Trace(funcName, "error: end")
+					return fmt.Errorf("branch %s has moved since it was created; force to delete it", a.Branch)
+				}
+			}
+		}
+	case journal.ActionRemove:
+		err := p.checkFree(a.Worktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		if a.Branch != "" {
+			list, err := git.GetWorktrees(ctx, p.project)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return err
+			}
+			for _, v := range list {
+				if v.BranchName() == a.Branch {
+					cmn.Trace(funcName, "error: end")
+					return fmt.Errorf("branch %s is checked out in worktree %s", a.Branch, v.Name)
+				}
+			}
+		}
+	case journal.ActionMove:
+		wt, err := git.GetWorktree(ctx, p.project, a.Worktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		if wt.Locked {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w: %s; unlock it to move it back", cmn.ErrWorktreeLocked, a.Worktree)
+		}
+		err = p.checkFree(a.OldWorktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		if a.OldBranch != "" && git.RefExists(ctx, p.project, git.RefsHeads+a.OldBranch) {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("branch %w: %s", cmn.ErrExists, a.OldBranch)
+		}
+	case journal.ActionDeleteBranch:
+		if force < 1 && git.RefExists(ctx, p.project, git.RefsHeads+a.Branch) {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("branch %w: %s; force to reset it", cmn.ErrExists, a.Branch)
+		}
+	case journal.ActionCreateBranch:
+		head, err := git.RevParse(ctx, p.project, git.RefsHeads+a.Branch)
+		if err == nil && head != a.Head && force < 1 {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("branch %s has moved since it was created; force to delete it", a.Branch)
+		}
+	case journal.ActionResetBranch:
+		head, err := git.RevParse(ctx, p.project, git.RefsHeads+a.Branch)
+		if err == nil && head != a.Head && force < 1 {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("branch %s has moved since it was reset; force to reset it again", a.Branch)
+		}
+	default:
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("unknown journal action: %s", a.Kind)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// checkFree checks that a worktree can be added by name: nothing but an empty
+// directory is in its place.
+func (p *Project) checkFree(name string) error {
+	entries, err := os.ReadDir(filepath.Join(p.Dir, name))
+	if err == nil && len(entries) > 0 {
+		return fmt.Errorf("worktree %w: %s", cmn.ErrExists, name)
+	}
+	return nil
+}
+
+// undo reverses an action, recording what it did in entry.
+func (p *Project) undo(ctx context.Context, entry *journal.Entry, a journal.Action, force int) error {
+	funcName := "wt.undo"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "action: %#v", a)
+
+	switch a.Kind {
+	case journal.ActionAdd:
+		wt, err := git.GetWorktree(ctx, p.project, a.Worktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		rmForce := force
+		if a.Locked {
+			rmForce = max(rmForce, 2)
+		}
+		_, err = git.WorktreeRemove(ctx, p.project, &cmn.CfgRm{Force: rmForce}, a.Worktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		entry.Add(removed(wt))
+
+		switch {
+		case a.NewBranch && a.Branch != "":
+			head, err := git.RevParse(ctx, p.project, git.RefsHeads+a.Branch)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return err
+			}
+			err = git.DeleteBranch(ctx, p.project, a.Branch)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return err
+			}
+			entry.Add(journal.Action{Kind: journal.ActionDeleteBranch, Branch: a.Branch, Head: head})
+		case a.OldHead != "":
+			err = p.resetBranch(ctx, entry, a.Branch, a.OldHead)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return err
+			}
+		}
+	case journal.ActionRemove:
+		config := &cmn.CfgMk{Quiet: true, Lock: a.Locked, LockReason: a.LockReason}
+		commitish := a.Head
+		created := false
+		if a.Branch != "" {
+			if git.RefExists(ctx, p.project, git.RefsHeads+a.Branch) {
+				commitish = a.Branch
+			} else {
+				config.Branch = a.Branch
+				created = true
+			}
+		}
+		_, err := git.WorktreeAdd(ctx, p.project, config, a.Worktree, commitish)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		wt, err := git.GetWorktree(ctx, p.project, a.Worktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		entry.Add(journal.Action{Kind: journal.ActionAdd, Worktree: a.Worktree, Branch: a.Branch, Head: wt.Head, NewBranch: created})
+
+		_, err = files.Carry(p.project, filepath.Join(p.Dir, p.DefaultBranch), wt.Path)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+	case journal.ActionMove:
+		_, err := git.WorktreeMove(ctx, p.project, &cmn.CfgMv{Force: force > 0}, a.Worktree, a.OldWorktree)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		moved := journal.Action{Kind: journal.ActionMove, OldWorktree: a.Worktree, Worktree: a.OldWorktree}
+		if a.OldBranch != "" {
+			err = git.RenameBranch(ctx, p.project, a.Branch, a.OldBranch)
+			if err != nil {
+				entry.Add(moved)
+				cmn.Trace(funcName, "error: end")
+				return err
+			}
+			moved.OldBranch, moved.Branch = a.Branch, a.OldBranch
+		}
+		entry.Add(moved)
+	case journal.ActionDeleteBranch:
+		err := git.CreateBranch(ctx, p.project, a.Branch, a.Head, force > 0)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		entry.Add(journal.Action{Kind: journal.ActionCreateBranch, Branch: a.Branch, Head: a.Head})
+	case journal.ActionCreateBranch:
+		head, err := git.RevParse(ctx, p.project, git.RefsHeads+a.Branch)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		err = git.DeleteBranch(ctx, p.project, a.Branch)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		entry.Add(journal.Action{Kind: journal.ActionDeleteBranch, Branch: a.Branch, Head: head})
+	case journal.ActionResetBranch:
+		err := p.resetBranch(ctx, entry, a.Branch, a.OldHead)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// resetBranch resets a branch to a commit, recording the reset in entry.
+func (p *Project) resetBranch(ctx context.Context, entry *journal.Entry, branch string, commit string) error {
+	head, err := git.RevParse(ctx, p.project, git.RefsHeads+branch)
+	if err != nil {
+		return err
+	}
+	err = git.CreateBranch(ctx, p.project, branch, commit, true)
+	if err != nil {
+		return err
+	}
+	entry.Add(journal.Action{Kind: journal.ActionResetBranch, Branch: branch, Head: commit, OldHead: head})
+	return nil
+}
+
+// rollback reverses the actions recorded in entry, latest first, after the
+// operation failed with err, and returns err with the outcome. Actions it
+// could not reverse stay in entry, for Undo to finish. Rolling back is not
+// interrupted by ctx.
+func (p *Project) rollback(ctx context.Context, entry *journal.Entry, w io.Writer, err error) error {
+	funcName := "wt.rollback"
+	cmn.Trace(funcName, "begin")
+
+	n := len(entry.Actions)
+	if n == 0 {
+		cmn.Trace(funcName, "end")
+		return err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	w = out(w)
+	undone := journal.New(journal.OpUndo) // Not recorded; the entry shrinks instead.
+	for i := n - 1; i >= 0; i-- {
+		a := entry.Actions[i]
+		undoErr := p.undo(ctx, undone, a, 0)
+		if undoErr != nil {
+			entry.Actions = entry.Actions[:i+1]
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w; rolled back %d of %d changes, but could not %s: %v; run undo to restore the rest", err, n-1-i, n, describeUndo(a), undoErr)
+		}
+		fmt.Fprintf(w, "Rolled back: %s\n", describeUndo(a))
+	}
+	entry.Actions = nil
+
+	cmn.Trace(funcName, "end")
+	return fmt.Errorf("%w; rolled back all %d changes", err, n)
+}
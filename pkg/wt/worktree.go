@@ -0,0 +1,192 @@
+package wt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/internal/pick"
+)
+
+const (
+	previous = "previous" // Name of the state holding the worktree Switch left.
+)
+
+// OpenedDir returns the directory the project was opened from.
+func (p *Project) OpenedDir() string {
+	return p.project.InitialDir
+}
+
+// WorktreeName returns the name of the worktree given as name, checked and
+// cleaned up by the project's rules for worktree names.
+func (p *Project) WorktreeName(name string) (string, error) {
+	return p.project.WorktreeName(name)
+}
+
+// HasHooks reports whether the project's config file gives commands for any
+// of the named hooks, e.g. "post-mk".
+func (p *Project) HasHooks(names ...string) bool {
+	for _, v := range names {
+		if len(p.project.Hooks[v]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the worktree of the project matching query, trying in turn an
+// exact name, an exact branch, a name prefix, a substring and a fuzzy match.
+// A query matching several worktrees at the first matching step is an error.
+func (p *Project) Find(ctx context.Context, query string) (Worktree, error) {
+	found, err := git.FindWorktree(ctx, p.project, query)
+	if err != nil {
+		return Worktree{}, err
+	}
+	return worktree(found), nil
+}
+
+// Pick lets the user pick a worktree of the project on the terminal, with the
+// project's picker. It returns ErrCancelled if the user picks nothing.
+func (p *Project) Pick(ctx context.Context, prompt string) (Worktree, error) {
+	picked, err := pick.Worktree(ctx, p.project, prompt)
+	if err != nil {
+		return Worktree{}, err
+	}
+	return worktree(picked), nil
+}
+
+// Current returns the worktree holding the directory the project was opened
+// from.
+func (p *Project) Current(ctx context.Context) (Worktree, error) {
+	current, err := git.CurrentWorktree(ctx, p.project)
+	if err != nil {
+		return Worktree{}, err
+	}
+	return worktree(current), nil
+}
+
+// Previous returns the worktree Switch last changed away from.
+func (p *Project) Previous(ctx context.Context) (Worktree, error) {
+	name, err := p.project.ReadState(previous)
+	if err != nil {
+		return Worktree{}, fmt.Errorf("no previous worktree")
+	}
+	found, err := git.GetWorktree(ctx, p.project, name)
+	if err != nil {
+		return Worktree{}, err
+	}
+	return worktree(found), nil
+}
+
+// Switch records the current worktree as the previous one and asks the shell
+// integration to change to the worktree at path. It returns false if the shell
+// integration is not active.
+func (p *Project) Switch(ctx context.Context, path string) (bool, error) {
+	funcName := "wt.Switch"
+	cmn.Trace(funcName, "begin")
+
+	current, err := git.CurrentWorktree(ctx, p.project)
+	if err == nil && current.Path != path {
+		cmn.Debug(funcName, "recording previous worktree: %s", current.Name)
+		err = p.project.WriteState(previous, current.Name)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return false, err
+		}
+	}
+
+	ok, err := cmn.RequestCd(path)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return false, err
+	}
+
+	cmn.Trace(funcName, "end")
+	return ok, nil
+}
+
+// Changes returns the uncommitted changes of a worktree, one line per path in
+// the format of 'git status --porcelain'.
+func (p *Project) Changes(ctx context.Context, w Worktree) ([]string, error) {
+	return git.Status(ctx, p.project, w.Path)
+}
+
+// Diff returns the uncommitted changes of a worktree as a diff against HEAD.
+func (p *Project) Diff(ctx context.Context, w Worktree) ([]byte, error) {
+	return git.Diff(ctx, p.project, w.Path)
+}
+
+// Log returns the one-line log of the latest count commits of a worktree.
+func (p *Project) Log(ctx context.Context, w Worktree, count int) ([]byte, error) {
+	return git.Log(ctx, p.project, w.Path, count)
+}
+
+// Lock locks a worktree, so git refuses to remove or prune it, with a reason
+// if given.
+func (p *Project) Lock(ctx context.Context, name string, reason string) (Worktree, error) {
+	funcName := "wt.Lock"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "name: %s; reason: %s", name, reason)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	defer unlock()
+
+	found, err := git.GetWorktree(ctx, p.project, name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	if found.Locked {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("%w already: %s", cmn.ErrWorktreeLocked, found.Name)
+	}
+
+	_, err = git.WorktreeLock(ctx, p.project, found.Name, reason)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("error locking worktree: %w", err)
+	}
+	found.Locked, found.LockReason = true, reason
+
+	cmn.Trace(funcName, "end")
+	return worktree(found), nil
+}
+
+// Unlock unlocks a locked worktree.
+func (p *Project) Unlock(ctx context.Context, name string) (Worktree, error) {
+	funcName := "wt.Unlock"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "name: %s", name)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	defer unlock()
+
+	found, err := git.GetWorktree(ctx, p.project, name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	if !found.Locked {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("worktree not locked: %s", found.Name)
+	}
+
+	_, err = git.WorktreeUnlock(ctx, p.project, found.Name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("error unlocking worktree: %w", err)
+	}
+	found.Locked, found.LockReason = false, ""
+
+	cmn.Trace(funcName, "end")
+	return worktree(found), nil
+}
@@ -0,0 +1,1216 @@
+// Package wt is the public API for managing a project of git worktrees the way
+// git-wt does: a project directory holding a clone of the default branch, a
+// .git-wt config file, and a worktree per branch beside it.
+//
+// Open a project from any directory within it, or Clone a new one, then work
+// on its worktrees through the Project. Options are explicit; the project's
+// config file supplies only what the options leave to it, such as how
+// worktree names with slashes, local files and hooks are handled.
+//
+// Each Project carries its own configuration, so several projects can be used
+// in one process. Calls changing a project hold its lock file while they
+// run, so changes from several processes do not interleave; see LockWait.
+// Those adding, removing or moving worktrees also record what they change in
+// the project's journal, from which Undo reverses the latest change.
+//
+// Failures wrap the Err values of the package where they apply, such as
+// ErrWorktreeDirty; test for them with errors.Is.
+package wt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/files"
+	"github.com/jason-dour/git-wt/internal/git"
+	"github.com/jason-dour/git-wt/internal/hook"
+	"github.com/jason-dour/git-wt/internal/journal"
+	"github.com/jason-dour/git-wt/internal/pick"
+)
+
+var (
+	ErrUsage          = cmn.ErrUsage          // Invalid options or arguments.
+	ErrInvalidName    = cmn.ErrInvalidName    // Worktree name rejected by the project's rules.
+	ErrNoProject      = cmn.ErrNoProject      // No config file in the directory or its parents.
+	ErrNoWorktree     = cmn.ErrNoWorktree     // No worktree of the project by a name.
+	ErrWorktreeDirty  = cmn.ErrWorktreeDirty  // Worktree needs forcing to be removed.
+	ErrWorktreeLocked = cmn.ErrWorktreeLocked // Worktree needs forcing twice to be removed, or unlocking to be moved.
+	ErrInsideWorktree = cmn.ErrInsideWorktree // Worktree to remove or move holds the working directory.
+	ErrExists         = cmn.ErrExists         // Project, worktree or branch to create exists.
+	ErrRemote         = cmn.ErrRemote         // Remote could not be reached or read.
+	ErrCancelled      = pick.ErrCancelled     // Nothing was picked.
+) // Errors returned by the package, to be tested with errors.Is.
+
+type (
+	Project struct {
+		Dir           string        // Path of the project directory.
+		DefaultBranch string        // Default branch; also the default worktree's name.
+		Remote        string        // Name of the remote used by the project.
+		Fetch         bool          // Whether the project asks for fetching before adding ('fetch').
+		MvBranch      bool          // Whether the project asks for renaming branches on move ('mv-branch').
+		LockWait      time.Duration // How long calls changing the project wait for another process to release its lock; zero fails at once.
+
+		project *cmn.Project // Configuration read from the project's config file.
+	} // Project of worktrees.
+
+	Worktree struct {
+		Path       string // Absolute path of the worktree.
+		Name       string // Path of the worktree relative to the project directory.
+		Head       string // Commit id checked out in the worktree.
+		Branch     string // Full ref of the checked out branch; empty if detached or bare.
+		Bare       bool   // Whether this is a bare repository entry.
+		Detached   bool   // Whether HEAD is detached.
+		Locked     bool   // Whether the worktree is locked.
+		LockReason string // Reason given when the worktree was locked.
+		Prunable   bool   // Whether git considers the worktree prunable.
+	} // Worktree of a project.
+
+	CloneOptions struct {
+		Dir      string        // Project directory to create; defaults to the repository name.
+		LockWait time.Duration // How long to wait for another process to release the project's lock; zero fails at once.
+		Out      io.Writer     // Receives progress messages; nil discards them.
+	} // Options for Clone.
+
+	AddOptions struct {
+		Commitish   string    // Commit-ish to check out; a pull or merge request as pr/N or mr/N.
+		FromDefault bool      // Whether to base the worktree on <remote>/<default branch>.
+		Branch      string    // New branch to create.
+		BranchReset string    // Branch to create or reset.
+		Track       bool      // Whether to set up tracking for the new branch.
+		Force       bool      // Whether to check out a branch checked out elsewhere.
+		NoCheckout  bool      // Whether to leave the new worktree unpopulated.
+		NoCopy      bool      // Whether to skip carrying local files from the default worktree.
+		Lock        bool      // Whether to lock the new worktree.
+		LockReason  string    // Reason for locking the new worktree.
+		Fetch       bool      // Whether to fetch the remote before resolving the commit-ish.
+		Quiet       bool      // Whether to suppress progress reporting.
+		Out         io.Writer // Receives progress messages, warnings and hook output; nil discards them.
+	} // Options for Project.Add.
+
+	RemoveOptions struct {
+		Force int       // Number of times removal is forced; twice removes locked worktrees.
+		Out   io.Writer // Receives progress messages, warnings and hook output; nil discards them.
+	} // Options for Project.Remove and Project.RemoveMerged.
+
+	MoveOptions struct {
+		Force    bool      // Whether to move onto the path of a missing worktree; locked worktrees need unlocking whatever the force.
+		Branch   bool      // Whether to rename the checked-out branch to the new name.
+		Upstream bool      // Whether the renamed branch tracks its namesake on the remote.
+		Out      io.Writer // Receives progress messages, warnings and hook output; nil discards them.
+	} // Options for Project.Move.
+
+	ResetOptions struct {
+		Branches  bool      // Whether to delete local branches except the default.
+		Worktrees bool      // Whether to delete worktrees except the default.
+		All       bool      // Whether to delete everything and clone again.
+		Force     int       // Number of times deletion is forced; twice deletes locked worktrees.
+		Out       io.Writer // Receives progress messages, warnings and hook output; nil discards them.
+	} // Options for Project.Reset.
+)
+
+// out returns w, or a writer discarding everything if w is nil.
+func out(w io.Writer) io.Writer {
+	if w == nil {
+		return io.Discard
+	}
+	return w
+}
+
+// worktree converts a worktree of the git package.
+func worktree(w git.Worktree) Worktree {
+	return Worktree{
+		Path:       w.Path,
+		Name:       w.Name,
+		Head:       w.Head,
+		Branch:     w.Branch,
+		Bare:       w.Bare,
+		Detached:   w.Detached,
+		Locked:     w.Locked,
+		LockReason: w.LockReason,
+		Prunable:   w.Prunable,
+	}
+}
+
+// gitWorktree converts a worktree to one of the git package.
+func (w Worktree) gitWorktree() git.Worktree {
+	return git.Worktree{
+		Path:       w.Path,
+		Name:       w.Name,
+		Head:       w.Head,
+		Branch:     w.Branch,
+		Bare:       w.Bare,
+		Detached:   w.Detached,
+		Locked:     w.Locked,
+		LockReason: w.LockReason,
+		Prunable:   w.Prunable,
+	}
+}
+
+// BranchName returns the short name of the checked out branch.
+func (w Worktree) BranchName() string {
+	return w.gitWorktree().BranchName()
+}
+
+// BranchLabel returns the branch shown for the worktree in lists, or
+// "(detached)" if it has none.
+func (w Worktree) BranchLabel() string {
+	return w.gitWorktree().BranchLabel()
+}
+
+// InProject reports whether the worktree lies within the project directory.
+func (w Worktree) InProject() bool {
+	return w.gitWorktree().InProject()
+}
+
+// lock takes the project's lock, waiting up to p.LockWait for it, and
+// returns the function releasing it.
+func (p *Project) lock(ctx context.Context) (func(), error) {
+	return p.project.Lock(ctx, p.LockWait)
+}
+
+// record appends an entry to the project's journal. Failing to record only
+// warns to w, as the operation is done by then.
+func (p *Project) record(entry *journal.Entry, w io.Writer) {
+	err := journal.Append(p.project, entry)
+	if err != nil {
+		fmt.Fprintf(out(w), "warning: could not record %s in the journal: %s\n", entry.Op, err.Error())
+	}
+}
+
+// Open opens the project holding dir, which may be the project directory or
+// any directory beneath it.
+func Open(dir string) (*Project, error) {
+	funcName := "wt.Open"
+	cmn.Trace(funcName, "begin")
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("invalid directory %s: %w", dir, err)
+	}
+
+	cfg, err := cmn.OpenProject(abs)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	cmn.Trace(funcName, "end")
+	return &Project{
+		Dir:           cfg.ProjectDir,
+		DefaultBranch: cfg.DefaultBranch,
+		Remote:        cfg.Remote,
+		Fetch:         cfg.Fetch,
+		MvBranch:      cfg.MvBranch,
+		project:       cfg,
+	}, nil
+}
+
+// Clone creates a project from a remote repository: it clones the remote's
+// default branch into the default worktree and writes the config file.
+func Clone(ctx context.Context, url string, opts CloneOptions) (*Project, error) {
+	funcName := "wt.Clone"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "url: %s; options: %#v", url, opts)
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	fmt.Fprintf(out(opts.Out), "Cloning %s.\n", url)
+
+	// Get default branch from remote repository.
+	defaultBranch, err := git.GetRemoteDefaultBranch(ctx, nil, url)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("could not retrieve default branch: %w", err)
+	}
+	cmn.Debug(funcName, "default branch: %s", defaultBranch)
+
+	// Define the project directory.
+	dir := opts.Dir
+	if dir == "" {
+		dir = strings.TrimSuffix(filepath.Base(url), ".git")
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("invalid directory %s: %w", opts.Dir, err)
+	}
+	cmn.Debug(funcName, "project dir: %s", dir)
+
+	// Taking the lock creates the state directory, and the project directory
+	// if missing; remove them again unless the clone succeeds, so a retry does
+	// not find the project existing. Neither is removed unless empty.
+	state := &cmn.Project{ProjectDir: dir}
+	_, err = os.Stat(dir)
+	dirExisted := err == nil
+	cloned := false
+	defer func() {
+		if cloned {
+			return
+		}
+		os.Remove(state.StateDir())
+		if !dirExisted {
+			os.Remove(dir)
+		}
+	}()
+
+	unlock, err := state.Lock(ctx, opts.LockWait)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	defer unlock()
+
+	for _, v := range []string{filepath.Join(dir, "."+cmn.Basename), filepath.Join(dir, defaultBranch)} {
+		if _, err := os.Stat(v); err == nil {
+			cmn.Trace(funcName, "error: end")
+			return nil, fmt.Errorf("project %w: %s", cmn.ErrExists, v)
+		}
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	// Clone the repository.
+	err = git.Clone(ctx, nil, url, defaultBranch, filepath.Join(dir, defaultBranch))
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	cloned = true
+
+	// Write config file to project path.
+	err = cmn.WriteConfig(dir, defaultBranch)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("error writing config file: %w", err)
+	}
+
+	project, err := Open(dir)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	project.LockWait = opts.LockWait
+
+	cmn.Trace(funcName, "end")
+	return project, nil
+}
+
+// List returns the worktrees known to the project's repository, including any
+// outside the project directory.
+func (p *Project) List(ctx context.Context) ([]Worktree, error) {
+	funcName := "wt.List"
+	cmn.Trace(funcName, "begin")
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	list, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, fmt.Errorf("error listing worktrees: %w", err)
+	}
+	worktrees := []Worktree{}
+	for _, v := range list {
+		worktrees = append(worktrees, worktree(v))
+	}
+
+	cmn.Trace(funcName, "end")
+	return worktrees, nil
+}
+
+// checkAdd scans the options of Add for proper use.
+func checkAdd(opts AddOptions) error {
+	funcName := "wt.checkAdd"
+	cmn.Trace(funcName, "begin")
+
+	cmn.Debug(funcName, "check mutually exclusive branch options")
+	if len(opts.Branch) > 0 && len(opts.BranchReset) > 0 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: set branch with either -b or -B; don't use both", cmn.ErrUsage)
+	}
+
+	cmn.Debug(funcName, "check track has a new branch in options")
+	if opts.Track && !(len(opts.Branch) > 0 || len(opts.BranchReset) > 0) {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: track requires new branch via -b or -B", cmn.ErrUsage)
+	}
+
+	cmn.Debug(funcName, "check reason has lock in options")
+	if len(opts.LockReason) > 0 && !opts.Lock {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: reason requires lock", cmn.ErrUsage)
+	}
+
+	cmn.Debug(funcName, "check commit-ish provided or implied by from-default")
+	if opts.FromDefault && len(opts.Commitish) > 0 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: from-default implies commit-ish; don't specify both", cmn.ErrUsage)
+	}
+	if !opts.FromDefault && len(opts.Commitish) == 0 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: commit-ish required unless from-default is set", cmn.ErrUsage)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// Add adds a worktree to the project, carries local files into it and runs
+// the post-mk hooks.
+func (p *Project) Add(ctx context.Context, name string, opts AddOptions) (Worktree, error) {
+	funcName := "wt.Add"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "name: %s; options: %#v", name, opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	defer unlock()
+	entry := journal.New(journal.OpMk)
+	defer p.record(entry, opts.Out)
+
+	w := out(opts.Out)
+	msg := w
+	if opts.Quiet {
+		msg = io.Discard
+	}
+
+	// Set the worktree name.
+	wtName, err := p.project.WorktreeName(name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	cmn.Debug(funcName, "worktree name: %s", wtName)
+
+	// Check options.
+	err = checkAdd(opts)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	if entries, err := os.ReadDir(filepath.Join(p.Dir, wtName)); err == nil && len(entries) > 0 {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("worktree %w: %s", cmn.ErrExists, wtName)
+	}
+	if len(opts.Branch) > 0 && git.RefExists(ctx, p.project, git.RefsHeads+opts.Branch) {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("branch %w: %s", cmn.ErrExists, opts.Branch)
+	}
+	config := &cmn.CfgMk{
+		Branch:      opts.Branch,
+		BranchReset: opts.BranchReset,
+		CheckoutNo:  opts.NoCheckout,
+		Force:       opts.Force,
+		Lock:        opts.Lock,
+		LockReason:  opts.LockReason,
+		Track:       opts.Track,
+		Quiet:       opts.Quiet,
+	}
+
+	// Set the commit-ish to be used.
+	commitish := opts.Commitish
+	if opts.FromDefault {
+		commitish = p.Remote + "/" + p.DefaultBranch
+	}
+	cmn.Debug(funcName, "commit-ish: %s", commitish)
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+
+	// Fetch the remote so commit-ish resolves against current refs.
+	if opts.Fetch {
+		fmt.Fprintf(msg, "Fetching %s.\n", p.Remote)
+		output, err := git.Fetch(ctx, p.project)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return Worktree{}, err
+		}
+		w.Write(output)
+	}
+
+	// Check if a PR/MR and grab ref.
+	prmr := []string{"pull/", "pr/", "merge-requests/", "mr/"}
+	if slices.ContainsFunc(prmr, func(s string) bool {
+		return strings.HasPrefix(commitish, s)
+	}) {
+		cmn.Debug(funcName, "pr/mr ref specified; finding commit id")
+		url, err := git.GetRemote(ctx, p.project, filepath.Join(p.Dir, p.DefaultBranch))
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return Worktree{}, err
+		}
+		cmn.Debug(funcName, "remote: %s", url)
+
+		commitish = strings.Replace(commitish, "pr/", "pull/", -1)
+		commitish = strings.Replace(commitish, "mr/", "merge-requests/", -1)
+		if !strings.HasSuffix(commitish, "/head") {
+			commitish = commitish + "/head"
+		}
+		cmn.Debug(funcName, "normalized ref: %s", commitish)
+
+		config.RefId, err = git.GetRemoteRefId(ctx, p.project, url, commitish)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return Worktree{}, err
+		}
+		cmn.Debug(funcName, "found commit id for ref: %s", config.RefId)
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+
+	// Note where a branch to reset points, for undoing.
+	oldHead := ""
+	if len(opts.BranchReset) > 0 {
+		oldHead, _ = git.RevParse(ctx, p.project, git.RefsHeads+opts.BranchReset)
+	}
+
+	// Add the worktree.
+	output, err := git.WorktreeAdd(ctx, p.project, config, wtName, commitish)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	w.Write(output)
+
+	wt, err := git.GetWorktree(ctx, p.project, wtName)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	entry.Add(journal.Action{
+		Kind:       journal.ActionAdd,
+		Worktree:   wtName,
+		Branch:     wt.BranchName(),
+		Head:       wt.Head,
+		NewBranch:  len(opts.Branch) > 0 || (len(opts.BranchReset) > 0 && oldHead == ""),
+		OldHead:    oldHead,
+		Locked:     opts.Lock,
+		LockReason: opts.LockReason,
+	})
+
+	// Carry local files from the default worktree.
+	if opts.NoCopy {
+		cmn.Debug(funcName, "skipping local files")
+	} else {
+		carried, err := files.Carry(p.project, filepath.Join(p.Dir, p.DefaultBranch), wt.Path)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return worktree(wt), err
+		}
+		for _, v := range carried {
+			fmt.Fprintf(msg, "Carried %s: %s\n", p.project.CopyMode, v)
+		}
+	}
+
+	// Run post-mk hooks in the new worktree.
+	err = hook.Run(ctx, p.project, hook.PostMk, wt.Path, hook.Env{
+		Worktree: wtName,
+		Path:     wt.Path,
+		Branch:   wt.BranchName(),
+		Base:     wt.Head,
+	}, w)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return worktree(wt), err
+	}
+
+	cmn.Trace(funcName, "end")
+	return worktree(wt), nil
+}
+
+// removed returns the journal action for removing a worktree.
+func removed(wt git.Worktree) journal.Action {
+	return journal.Action{
+		Kind:       journal.ActionRemove,
+		Worktree:   wt.Name,
+		Branch:     wt.BranchName(),
+		Head:       wt.Head,
+		Locked:     wt.Locked,
+		LockReason: wt.LockReason,
+	}
+}
+
+// remove removes a worktree, running the rm hooks around it.
+func (p *Project) remove(ctx context.Context, entry *journal.Entry, wt git.Worktree, opts RemoveOptions) error {
+	funcName := "wt.remove"
+	cmn.Trace(funcName, "begin")
+
+	// Refuse what git would refuse, before the hooks run.
+	if wt.Locked && opts.Force < 2 {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: %s; force twice to remove it", cmn.ErrWorktreeLocked, wt.Name)
+	}
+	if opts.Force < 1 {
+		lines, err := git.Status(ctx, p.project, wt.Path)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+		if len(lines) > 0 {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w: %s; force to remove it", cmn.ErrWorktreeDirty, wt.Name)
+		}
+	}
+
+	// Run pre-rm hooks in the worktree.
+	env := hook.Env{
+		Worktree: wt.Name,
+		Path:     wt.Path,
+		Branch:   wt.BranchName(),
+		Base:     wt.Head,
+	}
+	err := hook.Run(ctx, p.project, hook.PreRm, wt.Path, env, opts.Out)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	// Remove the worktree.
+	output, err := git.WorktreeRemove(ctx, p.project, &cmn.CfgRm{Force: opts.Force}, wt.Name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	out(opts.Out).Write(output)
+	entry.Add(removed(wt))
+
+	// Run post-rm hooks in the project directory.
+	err = hook.Run(ctx, p.project, hook.PostRm, p.Dir, env, opts.Out)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// Remove removes a worktree from the project, running the pre-rm and post-rm
+// hooks around it.
+func (p *Project) Remove(ctx context.Context, name string, opts RemoveOptions) error {
+	funcName := "wt.Remove"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "name: %s; options: %#v", name, opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	defer unlock()
+	entry := journal.New(journal.OpRm)
+	defer p.record(entry, opts.Out)
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	wtName, err := p.project.WorktreeName(name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	wt, err := git.GetWorktree(ctx, p.project, wtName)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	err = p.remove(ctx, entry, wt, opts)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// RemoveMerged removes the worktrees whose branch is merged into the default
+// branch, skipping locked worktrees unless removal is forced twice, and
+// returns the worktrees removed.
+func (p *Project) RemoveMerged(ctx context.Context, opts RemoveOptions) ([]Worktree, error) {
+	funcName := "wt.RemoveMerged"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "options: %#v", opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	defer unlock()
+	entry := journal.New(journal.OpRm)
+	defer p.record(entry, opts.Out)
+
+	w := out(opts.Out)
+
+	branches, err := git.GetMergedBranches(ctx, p.project, p.DefaultBranch)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+	merged := map[string]struct{}{}
+	for _, v := range branches {
+		merged[v] = struct{}{}
+	}
+
+	worktrees, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return nil, err
+	}
+
+	removed := []Worktree{}
+	failed := 0
+	for _, v := range worktrees {
+		if !v.InProject() || v.Bare || v.Detached || v.Branch == "" || v.Name == p.DefaultBranch {
+			continue
+		}
+		if _, ok := merged[v.BranchName()]; !ok {
+			cmn.Debug(funcName, "branch not merged: %s", v.BranchName())
+			continue
+		}
+		if v.Locked && opts.Force < 2 {
+			fmt.Fprintf(w, "Skipped locked worktree: %s\n", v.Name)
+			continue
+		}
+		if err := context.Cause(ctx); err != nil {
+			cmn.Trace(funcName, "error: end")
+			return removed, fmt.Errorf("stopped after removing %d merged worktrees: %w", len(removed), err)
+		}
+		err := p.remove(ctx, entry, v, opts)
+		if err != nil {
+			fmt.Fprintf(w, "Could not remove worktree %s: %s\n", v.Name, err.Error())
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "Removed worktree: %s\n", v.Name)
+		removed = append(removed, worktree(v))
+	}
+
+	if failed > 0 {
+		cmn.Trace(funcName, "error: end")
+		return removed, fmt.Errorf("could not remove %d merged worktrees", failed)
+	}
+
+	cmn.Trace(funcName, "end")
+	return removed, nil
+}
+
+// checkBranch checks that the branch of a worktree can be renamed, returning
+// the branch.
+func (p *Project) checkBranch(ctx context.Context, wtName string, newBranch string) (string, error) {
+	funcName := "wt.checkBranch"
+	cmn.Trace(funcName, "begin")
+
+	wt, err := git.GetWorktree(ctx, p.project, wtName)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return "", err
+	}
+	if wt.Detached || wt.Branch == "" {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("worktree has no branch to rename: %s", wtName)
+	}
+	if wt.BranchName() == p.DefaultBranch {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("cannot rename the default branch; use '%s default'", cmn.Basename)
+	}
+	if wt.BranchName() != newBranch && git.RefExists(ctx, p.project, git.RefsHeads+newBranch) {
+		cmn.Trace(funcName, "error: end")
+		return "", fmt.Errorf("branch %w: %s", cmn.ErrExists, newBranch)
+	}
+
+	cmn.Trace(funcName, "end")
+	return wt.BranchName(), nil
+}
+
+// Move moves a worktree within the project, optionally renaming its branch,
+// and runs the post-mv hooks.
+func (p *Project) Move(ctx context.Context, name string, newName string, opts MoveOptions) (Worktree, error) {
+	funcName := "wt.Move"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "name: %s; new name: %s; options: %#v", name, newName, opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	defer unlock()
+	entry := journal.New(journal.OpMv)
+	defer p.record(entry, opts.Out)
+
+	w := out(opts.Out)
+
+	if opts.Upstream && !opts.Branch {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("%w: upstream requires branch", cmn.ErrUsage)
+	}
+
+	wtCurr, err := p.project.WorktreeName(name)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	wtNew, err := p.project.WorktreeName(newName)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	cmn.Debug(funcName, "worktree name: %s; new worktree name: %s", wtCurr, wtNew)
+
+	current, err := git.GetWorktree(ctx, p.project, wtCurr)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	if current.Locked {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, fmt.Errorf("%w: %s; unlock it to move it", cmn.ErrWorktreeLocked, wtCurr)
+	}
+
+	// Check the branch can be renamed before moving anything. Branch names
+	// use slashes whatever the separator of worktree paths.
+	branch := ""
+	newBranch := filepath.ToSlash(wtNew)
+	if opts.Branch {
+		branch, err = p.checkBranch(ctx, wtCurr, newBranch)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return Worktree{}, err
+		}
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+
+	// Move the worktree.
+	output, err := git.WorktreeMove(ctx, p.project, &cmn.CfgMv{Force: opts.Force}, wtCurr, wtNew)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	w.Write(output)
+	moved := journal.Action{Kind: journal.ActionMove, OldWorktree: wtCurr, Worktree: wtNew}
+
+	// Rename the branch.
+	if opts.Branch && branch != newBranch {
+		err = git.RenameBranch(ctx, p.project, branch, newBranch)
+		if err != nil {
+			entry.Add(moved)
+			cmn.Trace(funcName, "error: end")
+			return Worktree{}, fmt.Errorf("moved worktree %s to %s, but could not rename branch %s: %w", wtCurr, wtNew, branch, err)
+		}
+		fmt.Fprintf(w, "Renamed branch: %s -> %s\n", branch, newBranch)
+		moved.OldBranch, moved.Branch = branch, newBranch
+	}
+	entry.Add(moved)
+
+	// Track the renamed branch's namesake on the remote.
+	if moved.OldBranch != "" && opts.Upstream {
+		upstream := p.Remote + "/" + newBranch
+		if !git.RefExists(ctx, p.project, "refs/remotes/"+upstream) {
+			upstream = ""
+		}
+		if upstream != "" || git.RefExists(ctx, p.project, newBranch+"@{upstream}") {
+			err = git.SetUpstream(ctx, p.project, newBranch, upstream)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return Worktree{}, err
+			}
+		}
+		if upstream != "" {
+			fmt.Fprintf(w, "Tracking: %s\n", upstream)
+		} else {
+			fmt.Fprintf(w, "No %s on the remote; upstream unset\n", newBranch)
+		}
+	}
+
+	// Run post-mv hooks in the moved worktree.
+	wt, err := git.GetWorktree(ctx, p.project, wtNew)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return Worktree{}, err
+	}
+	err = hook.Run(ctx, p.project, hook.PostMv, wt.Path, hook.Env{
+		Worktree:    wtNew,
+		Path:        wt.Path,
+		Branch:      wt.BranchName(),
+		Base:        wt.Head,
+		OldWorktree: wtCurr,
+	}, w)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return worktree(wt), err
+	}
+
+	cmn.Trace(funcName, "end")
+	return worktree(wt), nil
+}
+
+// Reset deletes worktrees, branches, or everything in the project but its
+// config, in which case the default branch is cloned again. Locked worktrees
+// and their branches are kept unless deletion is forced twice.
+//
+// Deleting worktrees or branches is all or nothing: if a step fails, or the
+// context is cancelled, what was deleted is restored as Undo would, though
+// uncommitted changes of deleted worktrees are lost. Resetting everything
+// clones first and changes nothing if the clone fails.
+func (p *Project) Reset(ctx context.Context, opts ResetOptions) error {
+	funcName := "wt.Reset"
+	cmn.Trace(funcName, "begin")
+	cmn.Debug(funcName, "options: %#v", opts)
+
+	unlock, err := p.lock(ctx)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	defer unlock()
+	entry := journal.New(journal.OpXx)
+	defer p.record(entry, opts.Out)
+
+	if !(opts.Branches || opts.Worktrees || opts.All) {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("%w: nothing to reset", cmn.ErrUsage)
+	}
+
+	// Delete everything and clone again.
+	if opts.All {
+		err := p.resetAll(ctx, entry, opts)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return err
+		}
+	}
+
+	// Delete worktrees, rolling back if a step fails.
+	if opts.Worktrees {
+		err := p.deleteWorktrees(ctx, entry, opts)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return p.rollback(ctx, entry, opts.Out, fmt.Errorf("error deleting worktrees: %w", err))
+		}
+	}
+
+	// Delete branches, rolling back the worktrees too if a step fails.
+	if opts.Branches {
+		err := p.deleteBranches(ctx, entry, opts)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return p.rollback(ctx, entry, opts.Out, fmt.Errorf("error deleting branches: %w", err))
+		}
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// resetAll replaces everything in the project directory but its config and
+// state with a new clone of the default branch, and runs the post-clone hooks.
+// The clone is made in a temporary directory beside the project and swapped
+// in only once it succeeds.
+func (p *Project) resetAll(ctx context.Context, entry *journal.Entry, opts ResetOptions) error {
+	funcName := "wt.resetAll"
+	cmn.Trace(funcName, "begin")
+
+	cmn.Debug(funcName, "determine remote for cloning after delete")
+	remote, err := git.GetRemote(ctx, p.project, filepath.Join(p.Dir, p.DefaultBranch))
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error determining remote to clone after reset: %w", err)
+	}
+	cmn.Debug(funcName, "remote: %s", remote)
+
+	cmn.Debug(funcName, "checking for locked worktrees")
+	if opts.Force < 2 {
+		list, err := git.GetWorktrees(ctx, p.project)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("error listing worktrees: %w", err)
+		}
+		locked := []string{}
+		for _, v := range list {
+			if v.InProject() && v.Locked {
+				locked = append(locked, v.Name)
+			}
+		}
+		if len(locked) > 0 {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w: %s; unlock them or force twice", cmn.ErrWorktreeLocked, strings.Join(locked, ", "))
+		}
+	}
+
+	// Clone next to the project first, so a failing clone changes nothing.
+	tmp, err := os.MkdirTemp(filepath.Dir(p.Dir), "."+filepath.Base(p.Dir)+".xx-")
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	cmn.Debug(funcName, "temporary directory: %s", tmp)
+	clone := filepath.Join(tmp, "new", p.DefaultBranch)
+	cmn.Debug(funcName, "cloning remote: %s", remote)
+	fmt.Fprintf(out(opts.Out), "Cloning %s.\n", remote)
+	err = git.Clone(ctx, p.project, remote, p.DefaultBranch, clone)
+	if err != nil {
+		os.RemoveAll(tmp)
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not clone the remote; the project is unchanged: %w", err)
+	}
+
+	cmn.Debug(funcName, "listing contents of project directory")
+	contents, err := os.ReadDir(p.Dir)
+	if err != nil {
+		os.RemoveAll(tmp)
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error reading directory contents: %w", err)
+	}
+	names := []string{}
+	for _, v := range contents {
+		if v.Name() == filepath.Base(p.project.StateDir()) {
+			cmn.Debug(funcName, "ignoring state directory: %s", v.Name())
+		} else if v.Name() == "."+cmn.Basename {
+			cmn.Debug(funcName, "ignoring config file: %s", v.Name())
+		} else if !v.IsDir() {
+			cmn.Debug(funcName, "ignoring non-worktree file: %s", v.Name())
+		} else {
+			names = append(names, v.Name())
+		}
+	}
+
+	// Swap the clone in by renaming, which is local and quick, so it is not
+	// interrupted once begun. A failing rename moves everything back.
+	old := filepath.Join(tmp, "old")
+	err = os.Mkdir(old, 0755)
+	if err != nil {
+		os.RemoveAll(tmp)
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+	err = p.swap(names, old, clone)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	// What the swap moved aside cannot be restored.
+	entry.Irreversible = true
+
+	cmn.Debug(funcName, "deleting old contents: %s", old)
+	err = os.RemoveAll(tmp)
+	if err != nil {
+		fmt.Fprintf(out(opts.Out), "warning: could not delete the old worktrees in %s: %s\n", old, err.Error())
+	}
+
+	cmn.Debug(funcName, "running post-clone hooks")
+	wt, err := git.GetWorktree(ctx, p.project, p.DefaultBranch)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+	err = hook.Run(ctx, p.project, hook.PostClone, wt.Path, hook.Env{
+		Worktree: wt.Name,
+		Path:     wt.Path,
+		Branch:   wt.BranchName(),
+		Base:     wt.Head,
+	}, opts.Out)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return err
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// swap moves the named entries of the project directory into old, then moves
+// clone into the project as its default worktree. If a move fails, it moves
+// the entries back and deletes old's parent, the temporary directory; if that
+// fails too, the error tells where the entries are.
+func (p *Project) swap(names []string, old string, clone string) error {
+	funcName := "wt.swap"
+	cmn.Trace(funcName, "begin")
+
+	moved := 0
+	var err error
+	for _, v := range names {
+		cmn.Debug(funcName, "moving aside: %s", v)
+		err = os.Rename(filepath.Join(p.Dir, v), filepath.Join(old, v))
+		if err != nil {
+			break
+		}
+		moved++
+	}
+	if err == nil {
+		cmn.Debug(funcName, "moving clone into project: %s", clone)
+		err = os.Rename(clone, filepath.Join(p.Dir, p.DefaultBranch))
+		if err == nil {
+			cmn.Trace(funcName, "end")
+			return nil
+		}
+	}
+	err = fmt.Errorf("could not swap the clone into the project: %w", err)
+
+	for _, v := range names[:moved] {
+		cmn.Debug(funcName, "moving back: %s", v)
+		restoreErr := os.Rename(filepath.Join(old, v), filepath.Join(p.Dir, v))
+		if restoreErr != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("%w; could not move %s back either, so move the directories in %s back into %s by hand: %v", err, v, old, p.Dir, restoreErr)
+		}
+	}
+	os.RemoveAll(filepath.Dir(old))
+
+	cmn.Trace(funcName, "error: end")
+	return fmt.Errorf("%w; the project is unchanged", err)
+}
+
+// deleteWorktrees deletes all worktrees except the default.
+func (p *Project) deleteWorktrees(ctx context.Context, entry *journal.Entry, opts ResetOptions) error {
+	funcName := "wt.deleteWorktrees"
+	cmn.Trace(funcName, "begin")
+
+	w := out(opts.Out)
+
+	// Retrieve list of worktrees.
+	cmn.Debug(funcName, "retrieving list of worktrees")
+	list, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error listing worktrees: %w", err)
+	}
+
+	// Build slice of worktrees to delete.
+	worktrees := []git.Worktree{}
+	for _, v := range list {
+		if v.Name == p.DefaultBranch {
+			cmn.Debug(funcName, "found worktree for default branch: %s; ignoring", v.Name)
+		} else if !v.InProject() {
+			cmn.Debug(funcName, "found worktree outside project: %s; ignoring", v.Path)
+		} else if v.Locked && opts.Force < 2 {
+			cmn.Debug(funcName, "found locked worktree: %s; ignoring", v.Name)
+			fmt.Fprintf(w, "Skipped locked worktree: %s\n", v.Name)
+		} else {
+			cmn.Debug(funcName, "found worktree to delete: %s", v.Name)
+			worktrees = append(worktrees, v)
+		}
+	}
+	cmn.Debug(funcName, "worktrees to delete: %d", len(worktrees))
+
+	// Iterate through slice of worktrees and delete them.
+	for i, v := range worktrees {
+		if err := context.Cause(ctx); err != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("stopped after deleting %d of %d worktrees: %w", i, len(worktrees), err)
+		}
+		cmn.Debug(funcName, "deleting worktree: %s", v.Name)
+		_, err := git.WorktreeRemove(ctx, p.project, &cmn.CfgRm{Force: max(opts.Force, 1)}, v.Name)
+		if err != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("error removing worktree: %w", err)
+		}
+		entry.Add(removed(v))
+		fmt.Fprintf(w, "Deleted worktree: %s\n", v.Name)
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
+
+// deleteBranches deletes all local branches except the default.
+func (p *Project) deleteBranches(ctx context.Context, entry *journal.Entry, opts ResetOptions) error {
+	funcName := "wt.deleteBranches"
+	cmn.Trace(funcName, "begin")
+
+	w := out(opts.Out)
+
+	branches, err := git.GetBranches(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error getting branches: %w", err)
+	}
+	cmn.Debug(funcName, "branches: %v", branches)
+
+	cmn.Debug(funcName, "retrieving worktrees")
+	worktrees, err := git.GetWorktrees(ctx, p.project)
+	if err != nil {
+		cmn.Trace(funcName, "error: end")
+		return fmt.Errorf("error retrieving worktrees: %w", err)
+	}
+
+	worktree_branches := make(map[string]struct{})
+	locked_branches := make(map[string]struct{})
+	for _, v := range worktrees {
+		if len(v.Branch) > 0 {
+			worktree_branches[v.BranchName()] = struct{}{}
+			if v.Locked {
+				locked_branches[v.BranchName()] = struct{}{}
+			}
+		}
+	}
+	cmn.Debug(funcName, "branches checked out in worktrees: %v", worktree_branches)
+
+	deleted := 0
+	for _, v := range branches {
+		if err := context.Cause(ctx); err != nil {
+			cmn.Trace(funcName, "error: end")
+			return fmt.Errorf("stopped after deleting %d branches: %w", deleted, err)
+		}
+		if v == p.DefaultBranch {
+			cmn.Debug(funcName, "found default branch: %s; ignoring", v)
+		} else if _, ok := locked_branches[v]; ok && opts.Force < 2 {
+			cmn.Debug(funcName, "found branch of locked worktree: %s; ignoring", v)
+			fmt.Fprintf(w, "Skipped branch of locked worktree: %s\n", v)
+		} else {
+			cmn.Debug(funcName, "deleting branch: %s", v)
+			if _, ok := worktree_branches[v]; ok {
+				cmn.Trace(funcName, "error: end")
+				return fmt.Errorf("branch checked out in worktree, remove worktree first: %s", v)
+			}
+			head, err := git.RevParse(ctx, p.project, git.RefsHeads+v)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return fmt.Errorf("error deleting branch: %w", err)
+			}
+			err = git.DeleteBranch(ctx, p.project, v)
+			if err != nil {
+				cmn.Trace(funcName, "error: end")
+				return fmt.Errorf("error deleting branch: %w", err)
+			}
+			entry.Add(journal.Action{Kind: journal.ActionDeleteBranch, Branch: v, Head: head})
+			fmt.Fprintf(w, "Deleted branch: %s\n", v)
+			deleted++
+		}
+	}
+
+	cmn.Trace(funcName, "end")
+	return nil
+}
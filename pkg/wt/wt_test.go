@@ -0,0 +1,146 @@
+package wt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jason-dour/git-wt/internal/cmn"
+	"github.com/jason-dour/git-wt/internal/git/gittest"
+	"github.com/jason-dour/git-wt/internal/journal"
+)
+
+// porcelain is 'git worktree list --porcelain' output for the default
+// worktree, a worktree on 'one' and a locked worktree on 'kept'.
+const porcelain = `worktree /project/main
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /project/one
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/one
+
+worktree /project/kept
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/kept
+locked on hold
+
+`
+
+// fakeProject returns a project in /project on the default branch 'main'
+// whose git commands are answered by fake.
+func fakeProject(fake *gittest.Fake) *Project {
+	return &Project{
+		Dir:           "/project",
+		DefaultBranch: "main",
+		Remote:        "origin",
+		project: &cmn.Project{
+			DefaultBranch: "main",
+			InitialDir:    "/project",
+			ProjectDir:    "/project",
+			Remote:        "origin",
+			Runner:        fake,
+		},
+	}
+}
+
+func TestDeleteBranches(t *testing.T) {
+	tests := []struct {
+		name      string
+		branches  string   // Output of listing the branches.
+		force     int      // Number of times deletion is forced.
+		deleteErr error    // Error deleting a branch.
+		deleted   []string // Commands deleting branches, in order.
+		output    string   // Progress messages.
+		err       string   // Part of the error expected, if any.
+	}{
+		{
+			name:     "loose branches",
+			branches: "kept\nloose\nmain\nother\n",
+			deleted:  []string{"branch -D loose", "branch -D other"},
+			output:   "Skipped branch of locked worktree: kept\nDeleted branch: loose\nDeleted branch: other\n",
+		},
+		{
+			name:     "branch of locked worktree forced",
+			branches: "kept\nloose\n",
+			force:    2,
+			deleted:  []string{},
+			err:      "branch checked out in worktree, remove worktree first: kept",
+		},
+		{
+			name:     "branch checked out",
+			branches: "loose\none\nzzz\n",
+			deleted:  []string{"branch -D loose"},
+			output:   "Deleted branch: loose\n",
+			err:      "branch checked out in worktree, remove worktree first: one",
+		},
+		{
+			name:      "delete fails",
+			branches:  "loose\n",
+			deleteErr: errors.New("exit status 1"),
+			deleted:   []string{"branch -D loose"},
+			err:       "error deleting branch: exit status 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := (&gittest.Fake{}).
+				On("for-each-ref", tt.branches, nil).
+				On("worktree list --porcelain", porcelain, nil).
+				On("rev-parse", "2222222222222222222222222222222222222222\n", nil).
+				On("branch -D", "", tt.deleteErr)
+			out := &bytes.Buffer{}
+			entry := journal.New(journal.OpXx)
+
+			err := fakeProject(fake).deleteBranches(context.Background(), entry, ResetOptions{Branches: true, Force: tt.force, Out: out})
+
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err.Error())
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+			if got := fake.Commands("branch -D"); strings.Join(got, "\n") != strings.Join(tt.deleted, "\n") {
+				t.Errorf("deleted: got %q, want %q", got, tt.deleted)
+			}
+			recorded := []string{}
+			for _, v := range entry.Actions {
+				if v.Kind != journal.ActionDeleteBranch || v.Head != "2222222222222222222222222222222222222222" {
+					t.Errorf("recorded %#v", v)
+				}
+				recorded = append(recorded, "branch -D "+v.Branch)
+			}
+			if tt.deleteErr == nil && strings.Join(recorded, "\n") != strings.Join(tt.deleted, "\n") {
+				t.Errorf("recorded: got %q, want %q", recorded, tt.deleted)
+			}
+			if out.String() != tt.output {
+				t.Errorf("output: got %q, want %q", out.String(), tt.output)
+			}
+			for _, v := range fake.Calls() {
+				if v.Dir != "/project/main" {
+					t.Errorf("git %s: ran in %s, want the default worktree", strings.Join(v.Args, " "), v.Dir)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteBranchesCancelled(t *testing.T) {
+	fake := (&gittest.Fake{}).
+		On("for-each-ref", "loose\nother\n", nil).
+		On("worktree list --porcelain", porcelain, nil)
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(cmn.ErrInterrupted)
+
+	err := fakeProject(fake).deleteBranches(ctx, journal.New(journal.OpXx), ResetOptions{Branches: true})
+
+	if !errors.Is(err, cmn.ErrInterrupted) {
+		t.Fatalf("got error %v, want %v", err, cmn.ErrInterrupted)
+	}
+	if got := fake.Commands("branch -D"); len(got) != 0 {
+		t.Errorf("deleted branches after cancelling: %q", got)
+	}
+}